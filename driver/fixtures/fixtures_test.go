@@ -24,7 +24,10 @@ var Suite = &fixtures.Suite{
 	Semantic: fixtures.SemanticConfig{
 		BlacklistTypes: []string{
 			"Name",
+			"Name_FullyQualified",
+			"Name_Relative",
 			"Scalar_String",
+			"Scalar_Encapsed",
 			"Scalar_EncapsedStringPart",
 			"Comment",
 			"Comment_Doc",
@@ -34,6 +37,26 @@ var Suite = &fixtures.Suite{
 			"Stmt_GroupUse",
 			"Param",
 			"Stmt_Function",
+			"Stmt_ClassMethod",
+			"Expr_Closure",
+			"Expr_ClosureUse",
+			"Expr_List",
+			"Expr_Array",
+			"Expr_ArrayItem",
+			"Stmt_Class",
+			"Stmt_Interface",
+			"Stmt_TryCatch",
+			"Stmt_Catch",
+			"Stmt_Finally",
+			"Stmt_Global",
+			"Stmt_Static",
+			"Stmt_StaticVar",
+			"Stmt_Trait",
+			"Stmt_Property",
+			"Stmt_PropertyProperty",
+			"Stmt_ClassConst",
+			"Stmt_TraitUseAdaptation_Alias",
+			"Stmt_TraitUseAdaptation_Precedence",
 		},
 	},
 }
@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	php "github.com/bblfsh/php-driver/driver/normalizer/phpast"
+	"github.com/bblfsh/php-driver/driver/normalizer/phpuast"
 
 	"github.com/bblfsh/sdk/v3/uast"
 	"github.com/bblfsh/sdk/v3/uast/nodes"
@@ -61,6 +62,79 @@ func (op opParts2Str) Construct(st *State, n nodes.Node) (nodes.Node, error) {
 	return op.orig.Construct(st, n)
 }
 
+// constNameIn checks if a name node refers to one of the case-insensitive constants, like "TRUE" or "\null".
+type constNameIn []string
+
+func (constNameIn) Kinds() nodes.Kind {
+	return nodes.KindObject
+}
+
+func (sel constNameIn) Check(st *State, n nodes.Node) (bool, error) {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return false, nil
+	}
+	var name nodes.String
+	switch uast.TypeOf(obj) {
+	case php.Name, php.FullyQualified:
+		if tok, ok := obj[uast.KeyToken].(nodes.String); ok {
+			name = tok
+		} else if parts, ok := obj["parts"].(nodes.Array); ok && len(parts) == 1 {
+			name, _ = parts[0].(nodes.String)
+		}
+	case uast.TypeOf(uast.Identifier{}):
+		name, _ = obj["Name"].(nodes.String)
+	}
+	for _, c := range sel {
+		if strings.EqualFold(string(name), c) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// staticNameTypes lists types of nodes used for names of variables and members that are known statically.
+// String literals are included, since names like ${'a'} or $a->{'b'} don't depend on runtime values.
+var staticNameTypes = []nodes.Value{
+	nodes.String(php.Name),
+	nodes.String(uast.TypeOf(uast.Identifier{})),
+	nodes.String(php.ScalarString),
+	nodes.String(uast.TypeOf(uast.String{})),
+}
+
+// staticName checks that a name field stores a static name, like in $a, $a->b or ${'a'}.
+func staticName(vr string) Op {
+	return Check(Has{uast.KeyType: In(staticNameTypes...)}, Var(vr))
+}
+
+// dynamicName checks that a name field stores an expression, like in $$a or $a->{$b}.
+// Such names cannot be resolved statically.
+func dynamicName(vr string) Op {
+	return Check(Not(Has{uast.KeyType: In(staticNameTypes...)}), Var(vr))
+}
+
+// keyDynamic is a field that is set to true on variables, properties and method calls with dynamic names.
+const keyDynamic = "Dynamic"
+
+// annDynamic annotates a node type with a dynamic name and sets the Dynamic field to true.
+func annDynamic(typ string, fields FieldRoles, roles ...role.Role) Mapping {
+	if fields == nil {
+		fields = make(FieldRoles)
+	}
+	fields["name"] = FieldRole{Op: dynamicName("name")}
+	fields[keyDynamic] = FieldRole{Add: true, Op: Bool(true)}
+	return AnnotateType(typ, fields, roles...)
+}
+
+// annStatic annotates a node type with a static name.
+func annStatic(typ string, fields FieldRoles, roles ...role.Role) Mapping {
+	if fields == nil {
+		fields = make(FieldRoles)
+	}
+	fields["name"] = FieldRole{Op: staticName("name")}
+	return AnnotateType(typ, fields, roles...)
+}
+
 var Native = Transformers([][]Transformer{
 	{Mappings(Annotations...)},
 	{RolesDedup()},
@@ -96,6 +170,11 @@ var Annotations = []Mapping{
 	}, Obj{
 		uast.KeyToken: Var("text"),
 	}), role.Comment, role.Noop, role.Documentation),
+	// created by the normalizer next to doc comments
+	AnnotateType(uast.TypeOf(phpuast.Doc{}), nil, role.Noop, role.Documentation),
+	AnnotateType(uast.TypeOf(phpuast.DocTag{}), FieldRoles{
+		"Argument": {Opt: true, Roles: role.Roles{role.Argument, role.Name}},
+	}, role.Documentation),
 
 	mapInternalProperty("left", role.Left),
 	mapInternalProperty("right", role.Right),
@@ -135,7 +214,19 @@ var Annotations = []Mapping{
 	AnnotateType(php.Array, nil, role.Expression, role.Literal, role.List),
 	AnnotateType(php.ArrayDimFetch, nil, role.Expression, role.List, role.Value, role.Entry),
 	AnnotateType(php.ArrayItem, nil, role.Expression, role.List, role.Entry),
-	AnnotateType(php.Variable, nil, role.Identifier, role.Variable),
+	AnnotateType(uast.TypeOf(phpuast.Array{}), FieldRoles{
+		"Kind": {Op: String("list")},
+	}, role.Expression, role.Literal, role.List),
+	AnnotateType(uast.TypeOf(phpuast.Array{}), FieldRoles{
+		"Kind": {Op: String("map")},
+	}, role.Expression, role.Literal, role.Map),
+	AnnotateType(uast.TypeOf(phpuast.ArrayItem{}), FieldRoles{
+		"Key":   {Opt: true, Roles: role.Roles{role.Key}},
+		"Value": {Roles: role.Roles{role.Value}},
+	}, role.Expression, role.Entry),
+	// dynamic names are not identifiers, but expressions that evaluate to one
+	annStatic(php.Variable, nil, role.Identifier, role.Variable),
+	annDynamic(php.Variable, nil, role.Expression, role.Variable, role.Dereference),
 	AnnotateType(php.NameRelative, nil, role.Expression, role.Identifier, role.Qualified, role.Incomplete),
 	AnnotateType(php.Nop, nil, role.Noop),
 	AnnotateType(php.Echo, nil, role.Statement, role.Call),
@@ -144,11 +235,14 @@ var Annotations = []Mapping{
 	AnnotateType(php.Empty, nil, role.Expression, role.Call),
 	AnnotateType(php.Isset, nil, role.Expression, role.Call),
 	AnnotateType(php.Unset, nil, role.Expression, role.Call),
-	AnnotateType(php.PropertyFetch, nil, role.Expression, role.Map, role.Identifier, role.Entry, role.Value),
+	annStatic(php.PropertyFetch, nil, role.Expression, role.Map, role.Identifier, role.Entry, role.Value),
+	annDynamic(php.PropertyFetch, nil, role.Expression, role.Map, role.Entry, role.Value, role.Dereference),
 
 	// no static in UAST
-	AnnotateType(php.StaticPropertyFetch, nil, role.Expression, role.Map, role.Identifier,
+	annStatic(php.StaticPropertyFetch, nil, role.Expression, role.Map, role.Identifier,
 		role.Entry, role.Value, role.Incomplete),
+	annDynamic(php.StaticPropertyFetch, nil, role.Expression, role.Map,
+		role.Entry, role.Value, role.Dereference, role.Incomplete),
 
 	// no error supress in UAST
 	AnnotateType(php.ErrorSuppress, nil, role.Expression, role.Incomplete),
@@ -158,15 +252,35 @@ var Annotations = []Mapping{
 	// no const in UAST
 	AnnotateType(php.Const, nil, role.Expression, role.Variable, role.Incomplete),
 	AnnotateType(php.StmtConst, nil, role.Expression, role.Variable, role.Incomplete),
-	AnnotateType(php.ConstFetch, nil, role.Expression, role.Variable, role.Incomplete),
+	AnnotateType(php.ConstFetch, FieldRoles{
+		"name": {Op: Check(constNameIn{"true", "false"}, Var("name"))},
+	}, role.Expression, role.Literal, role.Boolean),
+	AnnotateType(php.ConstFetch, FieldRoles{
+		"name": {Op: Check(constNameIn{"null"}, Var("name"))},
+	}, role.Expression, role.Literal, role.Null),
+	AnnotateType(php.ConstFetch, FieldRoles{
+		"name": {Op: Check(Not(constNameIn{"true", "false", "null"}), Var("name"))},
+	}, role.Expression, role.Variable, role.Incomplete),
+	// created by the normalizer for null constant
+	AnnotateType(uast.TypeOf(phpuast.Null{}), nil, role.Expression, role.Literal, role.Null),
 	AnnotateType(php.FullyQualified, nil, role.Expression, role.Variable, role.Incomplete),
+	// created by the normalizer for fully qualified and relative names
+	AnnotateType(uast.TypeOf(phpuast.AnchoredName{}), nil, role.Expression, role.Identifier, role.Qualified),
+	// created by the normalizer for declarations in namespaces
+	AnnotateType(uast.TypeOf(phpuast.Declaration{}), nil, role.Declaration, role.Qualified),
+	// created by the normalizer for resolved references
+	AnnotateType(uast.TypeOf(phpuast.ResolvedName{}), nil, role.Expression, role.Identifier, role.Qualified),
 	AnnotateType(php.ClassConstFetch, nil, role.Expression, role.Type, role.Incomplete),
 	AnnotateType(php.Clone, nil, role.Expression, role.Call, role.Incomplete),
 	AnnotateType(php.Closure, nil, role.Function, role.Declaration, role.Expression, role.Anonymous),
 	AnnotateType(php.ClosureUse, nil, role.Visibility, role.Incomplete),
+	// no capture role in UAST
+	AnnotateType(uast.TypeOf(phpuast.Closure{}), nil, role.Function, role.Anonymous, role.Incomplete),
+	AnnotateType(uast.TypeOf(phpuast.Capture{}), nil, role.Variable, role.Incomplete),
 	AnnotateType(php.Coalesce, nil, role.Expression, role.Incomplete),
 	AnnotateType(php.Use, nil, role.Alias),
 	AnnotateType(php.UseUse, nil, role.Alias),
+	AnnotateType(uast.TypeOf(phpuast.Use{}), nil, role.Statement, role.Import),
 	AnnotateType(php.Yield, nil, role.Return, role.Incomplete),
 	AnnotateType(php.YieldFrom, nil, role.Return, role.Incomplete),
 
@@ -181,17 +295,57 @@ var Annotations = []Mapping{
 	AnnotateType(php.Label, nil, role.Statement, role.Goto, role.Incomplete),
 
 	// no Nullable/Optional in UAST
-	AnnotateType(php.NullableType, nil, role.Type, role.Incomplete),
-	AnnotateType(php.Global, nil, role.Visibility, role.World),
-
-	// no Static in UAST
-	AnnotateType(php.Static, nil, role.Visibility, role.Type),
-	AnnotateType(php.StaticVar, nil, role.Expression, role.Identifier, role.Variable,
-		role.Visibility, role.Type),
+	AnnotateType(php.NullableType, nil, role.Type, role.Null),
+	AnnotateType(uast.TypeOf(phpuast.NullableType{}), nil, role.Type, role.Null),
+	AnnotateType(uast.TypeOf(phpuast.UnionType{}), nil, role.Type, role.Or),
+	AnnotateType(uast.TypeOf(phpuast.BuiltinType{}), nil, role.Type, role.Primitive),
+	AnnotateType(uast.TypeOf(phpuast.ByRef{}), nil, role.Type, role.TakeAddress),
+
+	// global binds local variables to global ones; no global scope in UAST
+	AnnotateType(php.Global, nil, role.Statement, role.Declaration, role.Variable, role.World),
+	AnnotateType(uast.TypeOf(phpuast.Global{}), nil, role.Statement, role.Declaration, role.Variable, role.World),
+
+	// static variables persist between function calls; no Static in UAST
+	AnnotateType(php.Static, nil, role.Statement, role.Declaration, role.Variable),
+	AnnotateType(php.StaticVar, FieldRoles{
+		"default": {Opt: true, Roles: role.Roles{role.Initialization}},
+	}, role.Declaration, role.Identifier, role.Variable),
+	AnnotateType(uast.TypeOf(phpuast.Static{}), nil, role.Statement, role.Declaration, role.Variable),
+	AnnotateType(uast.TypeOf(phpuast.StaticVar{}), FieldRoles{
+		"Default": {Opt: true, Roles: role.Roles{role.Initialization}},
+	}, role.Declaration, role.Variable),
 	AnnotateType(php.InlineHTML, FieldRoles{
 		"value": {Rename: uast.KeyToken},
 	}, role.String, role.Literal, role.Incomplete),
-	AnnotateType(php.List, nil, role.Call, role.List),
+
+	// no HTML in UAST; those nodes are only created if inline HTML parsing is enabled
+	AnnotateType(htmlElement, FieldRoles{
+		"Name": {Rename: uast.KeyToken},
+	}, role.Block, role.Incomplete),
+	AnnotateType(htmlEndTag, FieldRoles{
+		"Name": {Rename: uast.KeyToken},
+	}, role.Block, role.Incomplete),
+	AnnotateType(htmlAttribute, nil, role.Map, role.Entry, role.Incomplete),
+	AnnotateType(htmlText, FieldRoles{
+		"Text": {Rename: uast.KeyToken},
+	}, role.String, role.Literal, role.Incomplete),
+	AnnotateType(htmlComment, FieldRoles{
+		"Text": {Rename: uast.KeyToken},
+	}, role.Comment, role.Noop),
+	AnnotateType(htmlDoctype, FieldRoles{
+		"Text": {Rename: uast.KeyToken},
+	}, role.Noop, role.Incomplete),
+	// list() is a destructuring target, not a call
+	AnnotateType(php.List, nil, role.Expression, role.List),
+	AnnotateType(uast.TypeOf(phpuast.Destructuring{}), FieldRoles{
+		"Pattern": {Roles: role.Roles{role.Left}},
+		"Value":   {Roles: role.Roles{role.Right}},
+	}, role.Expression, role.Assignment),
+	AnnotateType(uast.TypeOf(phpuast.ListPattern{}), nil, role.Expression, role.List),
+	AnnotateType(uast.TypeOf(phpuast.DestructuringTarget{}), FieldRoles{
+		"Key":    {Opt: true, Roles: role.Roles{role.Key}},
+		"Target": {Roles: role.Roles{role.Left}},
+	}, role.Entry),
 
 	// Operators
 	AnnotateType(php.BinaryOpPlus, nil, role.Expression, role.Operator, role.Add),
@@ -274,14 +428,35 @@ var Annotations = []Mapping{
 	}, role.Catch, role.Type),
 
 	AnnotateType(php.Finally, nil, role.Statement, role.Finally),
+	AnnotateType(uast.TypeOf(phpuast.Try{}), FieldRoles{
+		"Body":    {Roles: role.Roles{role.Try, role.Body}},
+		"Catches": {Arr: true, Roles: role.Roles{role.Try}},
+		"Finally": {Opt: true, Roles: role.Roles{role.Finally, role.Body}},
+	}, role.Statement, role.Try),
+	AnnotateType(uast.TypeOf(phpuast.Catch{}), FieldRoles{
+		"Types": {Arr: true, Roles: role.Roles{role.Catch, role.Type}},
+		"Var":   {Opt: true, Roles: role.Roles{role.Catch, role.Variable}},
+		"Body":  {Roles: role.Roles{role.Catch, role.Body}},
+	}, role.Catch),
 
 	// Class
 	// FIXME: php-parser doesn't give Visibility information (public, private, etc)
 	AnnotateType(php.Class, FieldRoles{
+		"name":       {Op: Check(NotNil(), Var("name"))},
 		"extends":    {Roles: role.Roles{role.Base}, Opt: true},
 		"implements": {Arr: true, Roles: role.Roles{role.Implements}},
 		"stmts":      {Arr: true, Roles: role.Roles{role.Type, role.Body}},
 	}, role.Statement, role.Declaration, role.Type),
+	// anonymous classes are declared in a "new" expression
+	AnnotateType(php.Class, FieldRoles{
+		"name":       {Op: Is(nil)},
+		"extends":    {Roles: role.Roles{role.Base}, Opt: true},
+		"implements": {Arr: true, Roles: role.Roles{role.Implements}},
+		"stmts":      {Arr: true, Roles: role.Roles{role.Type, role.Body}},
+	}, role.Expression, role.Declaration, role.Type, role.Anonymous),
+	AnnotateType(uast.TypeOf(phpuast.AnonymousClass{}), nil, role.Expression, role.Declaration, role.Type,
+		role.Anonymous),
+	AnnotateType(uast.TypeOf(phpuast.ClassLike{}), nil, role.Declaration, role.Type),
 
 	// plus no const in UAST
 	AnnotateType(php.ClassConst, nil, role.Type, role.Variable, role.Incomplete),
@@ -289,6 +464,9 @@ var Annotations = []Mapping{
 	// no member role in UAST
 	AnnotateType(php.Property, nil, role.Type, role.Variable, role.Incomplete),
 	AnnotateType(php.PropertyProperty, nil, role.Type, role.Variable, role.Incomplete),
+	AnnotateType(uast.TypeOf(phpuast.Field{}), nil, role.Declaration, role.Variable),
+	// no modifiers role in UAST
+	AnnotateType(uast.TypeOf(phpuast.Modifiers{}), nil, role.Visibility, role.Incomplete),
 
 	// ditto
 	AnnotateType(php.ClassMethod, nil, role.Type, role.Function),
@@ -347,6 +525,8 @@ var Annotations = []Mapping{
 
 	// Encapsed; incomplete: no encapsed/ string varsubst in UAST
 	AnnotateType(php.Encapsed, nil, role.Expression, role.Literal, role.String, role.Incomplete),
+	// created by the normalizer for encapsed strings
+	AnnotateType(uast.TypeOf(phpuast.StringTemplate{}), nil, role.Expression, role.Literal, role.String),
 	AnnotateType(php.EncapsedStringPart, FieldRoles{
 		"value": {Rename: uast.KeyToken},
 	}, role.Expression, role.Identifier, role.Value),
@@ -371,9 +551,12 @@ var Annotations = []Mapping{
 		"class": {role.Type, role.Receiver},
 	}, role.Expression, role.Call, role.Identifier),
 
-	AnnotateType(php.MethodCall, FieldRoles{
+	annStatic(php.MethodCall, FieldRoles{
 		"var": {Roles: role.Roles{role.Receiver, role.Identifier}},
 	}, role.Expression, role.Call, role.Identifier),
+	annDynamic(php.MethodCall, FieldRoles{
+		"var": {Roles: role.Roles{role.Receiver, role.Identifier}},
+	}, role.Expression, role.Call, role.Dereference),
 
 	// Function declarations
 	AnnotateType(php.Function, MapObj(Obj{
@@ -415,6 +598,7 @@ var Annotations = []Mapping{
 	AnnotateType(php.Include, ObjRoles{
 		"expr": {role.Import, role.Pathname},
 	}, role.Import),
+	AnnotateType(uast.TypeOf(phpuast.IncludePath{}), nil, role.Expression, role.Import, role.Pathname),
 
 	// Instanceof
 	AnnotateType(php.Instanceof, FieldRoles{
@@ -432,6 +616,8 @@ var Annotations = []Mapping{
 	// Traits
 	AnnotateType(php.Trait, nil, role.Type, role.Declaration),
 	AnnotateType(php.TraitUse, nil, role.Base),
+	AnnotateType(uast.TypeOf(phpuast.TraitAlias{}), nil, role.Statement, role.Alias),
+	AnnotateType(uast.TypeOf(phpuast.TraitPrecedence{}), nil, role.Statement, role.Alias),
 	AnnotateType(php.TraitPrecedence, FieldRoles{
 		"insteadof": {Arr: true, Roles: role.Roles{role.Alias, role.Incomplete}},
 	}, role.Base, role.Alias, role.Incomplete),
@@ -440,6 +626,7 @@ var Annotations = []Mapping{
 	AnnotateType(php.New, ObjRoles{
 		"class": {role.Type},
 	}, role.Expression, role.Initialization, role.Call),
+	AnnotateType(uast.TypeOf(phpuast.New{}), nil, role.Expression, role.Initialization, role.Call),
 
 	//Switch
 	AnnotateType(php.Switch, nil, role.Switch),
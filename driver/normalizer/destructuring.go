@@ -0,0 +1,193 @@
+package normalizer
+
+import (
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+
+	php "github.com/bblfsh/php-driver/driver/normalizer/phpast"
+	"github.com/bblfsh/php-driver/driver/normalizer/phpuast"
+)
+
+var (
+	typeArray     = uast.TypeOf(phpuast.Array{})
+	typeArrayItem = uast.TypeOf(phpuast.ArrayItem{})
+	typePattern   = uast.TypeOf(phpuast.ListPattern{})
+	typeTarget    = uast.TypeOf(phpuast.DestructuringTarget{})
+)
+
+// listPattern is an op that converts a destructuring target of an assignment, like "list($a, 'k' => $b)" or
+// "[, [$a], $b]", into a ListPattern node and stores it in a variable.
+//
+// Nested lists are converted as well, thus the op receives array literals that were already normalized,
+// but constructs native list and array nodes on reverse.
+type listPattern struct {
+	vr string
+}
+
+func (op listPattern) Kinds() nodes.Kind {
+	return nodes.KindObject
+}
+
+func (op listPattern) Check(st *State, n nodes.Node) (bool, error) {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return false, nil
+	}
+	pat, ok := toPattern(obj)
+	if !ok {
+		return false, nil
+	}
+	err := st.SetVar(op.vr, pat)
+	return err == nil, err
+}
+
+func (op listPattern) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	if n != nil {
+		return nil, ErrUnexpectedValue.New(n)
+	}
+	v, err := st.MustGetVar(op.vr)
+	if err != nil {
+		return nil, err
+	}
+	pat, ok := v.(nodes.Object)
+	if !ok || uast.TypeOf(pat) != typePattern {
+		return nil, ErrUnexpectedType.New(nodes.Object{}, v)
+	}
+	return fromPattern(pat)
+}
+
+// copyFields copies a set of fields from one object to another, renaming them. Fields that are missing
+// in the source object are skipped. It returns false if the source object has any other fields.
+func copyFields(dst, src nodes.Object, names map[string]string) bool {
+	for k, v := range src {
+		name, ok := names[k]
+		if !ok {
+			return false
+		}
+		if name != "" {
+			dst[name] = v
+		}
+	}
+	return true
+}
+
+// toPattern converts a native list or a normalized short array to a ListPattern node.
+func toPattern(obj nodes.Object) (nodes.Object, bool) {
+	out := nodes.Object{uast.KeyType: nodes.String(typePattern)}
+	var items nodes.Array
+	switch uast.TypeOf(obj) {
+	case php.List:
+		if !copyFields(out, obj, map[string]string{
+			uast.KeyType: "",
+			uast.KeyPos:  uast.KeyPos,
+			"items":      "",
+			"comments":   "Comments",
+		}) {
+			return nil, false
+		}
+		items, _ = obj["items"].(nodes.Array)
+		out["Short"] = nodes.Bool(false)
+	case typeArray:
+		if obj["Short"] != nodes.Bool(true) || !copyFields(out, obj, map[string]string{
+			uast.KeyType: "",
+			uast.KeyPos:  uast.KeyPos,
+			"Kind":       "",
+			"Short":      "Short",
+			"Items":      "",
+			"Comments":   "Comments",
+		}) {
+			return nil, false
+		}
+		items, _ = obj["Items"].(nodes.Array)
+	default:
+		return nil, false
+	}
+	targets := make(nodes.Array, 0, len(items))
+	for _, it := range items {
+		if it == nil {
+			// skipped element
+			targets = append(targets, nil)
+			continue
+		}
+		item, ok := it.(nodes.Object)
+		if !ok || uast.TypeOf(item) != typeArrayItem {
+			return nil, false
+		}
+		t := nodes.Object{uast.KeyType: nodes.String(typeTarget)}
+		// spread items cannot be used in destructuring
+		if !copyFields(t, item, map[string]string{
+			uast.KeyType: "",
+			uast.KeyPos:  uast.KeyPos,
+			"Key":        "Key",
+			"Value":      "",
+			"ByRef":      "ByRef",
+			"Comments":   "Comments",
+		}) {
+			return nil, false
+		}
+		target := item["Value"]
+		if sub, ok := target.(nodes.Object); ok {
+			switch uast.TypeOf(sub) {
+			case php.List, typeArray:
+				if target, ok = toPattern(sub); !ok {
+					return nil, false
+				}
+			}
+		}
+		t["Target"] = target
+		targets = append(targets, t)
+	}
+	out["Targets"] = targets
+	return out, true
+}
+
+// fromPattern converts a ListPattern node back to a native list or array node.
+func fromPattern(pat nodes.Object) (nodes.Object, error) {
+	targets, _ := pat["Targets"].(nodes.Array)
+	items := make(nodes.Array, 0, len(targets))
+	for _, t := range targets {
+		if t == nil {
+			items = append(items, nil)
+			continue
+		}
+		target, ok := t.(nodes.Object)
+		if !ok || uast.TypeOf(target) != typeTarget {
+			return nil, ErrUnexpectedType.New(nodes.Object{}, t)
+		}
+		item := nodes.Object{uast.KeyType: nodes.String(php.ArrayItem)}
+		copyFields(item, target, map[string]string{
+			uast.KeyType: "",
+			uast.KeyPos:  uast.KeyPos,
+			"Key":        "key",
+			"Target":     "",
+			"ByRef":      "byRef",
+			"Comments":   "comments",
+		})
+		value := target["Target"]
+		if sub, ok := value.(nodes.Object); ok && uast.TypeOf(sub) == typePattern {
+			var err error
+			if value, err = fromPattern(sub); err != nil {
+				return nil, err
+			}
+		}
+		item["value"] = value
+		items = append(items, item)
+	}
+	out := nodes.Object{uast.KeyType: nodes.String(php.List)}
+	if pat["Short"] == nodes.Bool(true) {
+		out = nodes.Object{
+			uast.KeyType: nodes.String(php.Array),
+			"attributes": nodes.Object{"kind": nodes.Int(2)},
+		}
+	}
+	copyFields(out, pat, map[string]string{
+		uast.KeyType: "",
+		uast.KeyPos:  uast.KeyPos,
+		"Short":      "",
+		"Targets":    "",
+		"Comments":   "comments",
+	})
+	out["items"] = items
+	return out, nil
+}
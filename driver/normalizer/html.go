@@ -0,0 +1,416 @@
+package normalizer
+
+import (
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+
+	php "github.com/bblfsh/php-driver/driver/normalizer/phpast"
+)
+
+// envParseHTML is the name of an environment variable that enables parsing of inline HTML statements into a list
+// of HTML nodes. The parsing is disabled by default and is enabled if the variable is set to a non-empty value
+// when the driver starts.
+const envParseHTML = "PHP_PARSE_INLINE_HTML"
+
+// Types of HTML nodes.
+const (
+	htmlElement   = "HTMLElement"
+	htmlEndTag    = "HTMLEndTag"
+	htmlAttribute = "HTMLAttribute"
+	htmlText      = "HTMLText"
+	htmlComment   = "HTMLComment"
+	htmlDoctype   = "HTMLDoctype"
+)
+
+// keyHTML is a field of inline HTML statements that stores parsed HTML nodes.
+const keyHTML = "html"
+
+// htmlVoidElements lists elements that have no content and no end tag.
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlRawElements lists elements with a content that is not parsed as HTML.
+var htmlRawElements = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true,
+}
+
+// inlineHTML is a code transformation that parses the content of inline HTML statements.
+//
+// PHP code splits the markup into multiple statements, thus elements are not required to be closed in the same
+// statement. Elements that are closed in one of the following statements are left open, and end tags that
+// don't match any element are stored as separate HTMLEndTag nodes.
+//
+// The transformation does nothing unless it is enabled.
+type inlineHTML struct {
+	enabled bool
+}
+
+// OnCode implements CodeTransformer.
+func (t inlineHTML) OnCode(code string) Transformer {
+	return TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+		if !t.enabled || uast.TypeOf(obj) != php.InlineHTML {
+			return obj, false, nil
+		}
+		val, ok := obj["value"].(nodes.String)
+		if !ok {
+			return obj, false, nil
+		}
+		pos := uast.PositionsOf(obj)
+		start := pos.Start()
+		if start == nil {
+			return obj, false, nil
+		}
+		off := int(start.Offset)
+		if off+len(val) > len(code) || code[off:off+len(val)] != string(val) {
+			// the value doesn't match the source, offsets would be wrong
+			return obj, false, nil
+		}
+		p := htmlParser{s: string(val), off: off}
+		obj = obj.CloneObject()
+		obj[keyHTML] = p.parse()
+		return obj, true, nil
+	})
+}
+
+// htmlParser is a simple HTML parser that is tolerant to partial markup.
+type htmlParser struct {
+	s   string // source text
+	off int    // offset of the text in the file
+	i   int    // current position in the text
+}
+
+// htmlOpen is an element that was not closed yet.
+type htmlOpen struct {
+	name  string
+	node  nodes.Object
+	nodes nodes.Array
+}
+
+func (p *htmlParser) parse() nodes.Array {
+	var (
+		out   nodes.Array
+		stack []*htmlOpen
+	)
+	add := func(n nodes.Object) {
+		if len(stack) == 0 {
+			out = append(out, n)
+		} else {
+			last := stack[len(stack)-1]
+			last.nodes = append(last.nodes, n)
+		}
+	}
+	// closeTo closes elements from the top of the stack including the element at index i.
+	// The element at index i ends at the given offset, if it's not negative.
+	closeTo := func(i, end int) {
+		for len(stack) > i {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			e := end
+			if len(stack) != i || e < 0 {
+				e = p.endOf(last)
+			}
+			last.node["Children"] = last.nodes
+			last.node[uast.KeyPos] = p.pos(p.startOf(last.node), e)
+			add(last.node)
+		}
+	}
+	for p.i < len(p.s) {
+		if n := p.text(); n != nil {
+			add(n)
+			continue
+		} else if p.i >= len(p.s) {
+			break
+		}
+		switch {
+		case strings.HasPrefix(p.s[p.i:], "<!--"):
+			add(p.comment())
+		case strings.HasPrefix(p.s[p.i:], "<!"):
+			add(p.doctype())
+		case strings.HasPrefix(p.s[p.i:], "</"):
+			start := p.i
+			name := p.endTag()
+			i := len(stack) - 1
+			for ; i >= 0; i-- {
+				if stack[i].name == name {
+					break
+				}
+			}
+			if i < 0 {
+				add(nodes.Object{
+					uast.KeyType: nodes.String(htmlEndTag),
+					uast.KeyPos:  p.pos(start, p.i),
+					"Name":       nodes.String(name),
+				})
+				continue
+			}
+			closeTo(i, p.i)
+		default:
+			el, name, closed := p.startTag()
+			if closed || htmlVoidElements[name] {
+				add(el)
+				continue
+			}
+			open := &htmlOpen{name: name, node: el}
+			if htmlRawElements[name] {
+				if n := p.rawText(name); n != nil {
+					open.nodes = append(open.nodes, n)
+				}
+			}
+			stack = append(stack, open)
+		}
+	}
+	closeTo(0, -1)
+	return out
+}
+
+// pos creates a positions node for a range of the text.
+func (p *htmlParser) pos(start, end int) nodes.Object {
+	return uast.Positions{
+		uast.KeyStart: {Offset: uint32(p.off + start)},
+		uast.KeyEnd:   {Offset: uint32(p.off + end)},
+	}.ToObject()
+}
+
+// startOf returns the start offset of the node in the text.
+func (p *htmlParser) startOf(n nodes.Object) int {
+	return int(uast.PositionsOf(n).Start().Offset) - p.off
+}
+
+// endOf returns the end offset of an element in the text, if it was not closed explicitly.
+func (p *htmlParser) endOf(el *htmlOpen) int {
+	n := el.node
+	if len(el.nodes) != 0 {
+		n, _ = el.nodes[len(el.nodes)-1].(nodes.Object)
+	}
+	return int(uast.PositionsOf(n).End().Offset) - p.off
+}
+
+// isTagStart checks if there is a start of a tag, an end tag, a comment or a doctype at the position.
+func (p *htmlParser) isTagStart(i int) bool {
+	if p.s[i] != '<' || i+1 >= len(p.s) {
+		return false
+	}
+	c := p.s[i+1]
+	if c == '!' {
+		return true
+	}
+	if c == '/' {
+		return i+2 < len(p.s) && isASCIILetter(p.s[i+2])
+	}
+	return isASCIILetter(c)
+}
+
+// text reads a text node. It returns nil if there is a tag at the current position,
+// or if the text consists of whitespace only.
+func (p *htmlParser) text() nodes.Object {
+	start := p.i
+	for p.i < len(p.s) && !p.isTagStart(p.i) {
+		p.i++
+	}
+	if p.i == start || strings.TrimSpace(p.s[start:p.i]) == "" {
+		return nil
+	}
+	return nodes.Object{
+		uast.KeyType: nodes.String(htmlText),
+		uast.KeyPos:  p.pos(start, p.i),
+		"Text":       nodes.String(p.s[start:p.i]),
+	}
+}
+
+// rawText reads the content of a raw text element up to its end tag.
+func (p *htmlParser) rawText(name string) nodes.Object {
+	start := p.i
+	end := indexASCIIFold(p.s[p.i:], "</"+name)
+	if end < 0 {
+		p.i = len(p.s)
+	} else {
+		p.i += end
+	}
+	if p.i == start {
+		return nil
+	}
+	return nodes.Object{
+		uast.KeyType: nodes.String(htmlText),
+		uast.KeyPos:  p.pos(start, p.i),
+		"Text":       nodes.String(p.s[start:p.i]),
+	}
+}
+
+// skipTo moves the position after the first occurrence of the substring, or to the end of the text.
+// It returns the position of the substring.
+func (p *htmlParser) skipTo(sub string) int {
+	i := strings.Index(p.s[p.i:], sub)
+	if i < 0 {
+		p.i = len(p.s)
+		return p.i
+	}
+	i += p.i
+	p.i = i + len(sub)
+	return i
+}
+
+func (p *htmlParser) comment() nodes.Object {
+	start := p.i
+	p.i += len("<!--")
+	end := p.skipTo("-->")
+	return nodes.Object{
+		uast.KeyType: nodes.String(htmlComment),
+		uast.KeyPos:  p.pos(start, p.i),
+		"Text":       nodes.String(p.s[start+len("<!--") : end]),
+	}
+}
+
+func (p *htmlParser) doctype() nodes.Object {
+	start := p.i
+	p.i += len("<!")
+	end := p.skipTo(">")
+	return nodes.Object{
+		uast.KeyType: nodes.String(htmlDoctype),
+		uast.KeyPos:  p.pos(start, p.i),
+		"Text":       nodes.String(p.s[start+len("<!") : end]),
+	}
+}
+
+// endTag reads an end tag and returns the name of the element.
+func (p *htmlParser) endTag() string {
+	p.i += len("</")
+	name := p.name()
+	p.skipTo(">")
+	return name
+}
+
+// name reads a tag or attribute name and returns it in lower case.
+func (p *htmlParser) name() string {
+	start := p.i
+	for p.i < len(p.s) && !isHTMLNameEnd(p.s[p.i]) {
+		p.i++
+	}
+	return strings.ToLower(p.s[start:p.i])
+}
+
+// startTag reads a start tag with attributes. It returns an element node, its name,
+// and reports if the element was closed with "/>".
+func (p *htmlParser) startTag() (nodes.Object, string, bool) {
+	start := p.i
+	p.i++
+	name := p.name()
+	var (
+		attrs  nodes.Array
+		closed bool
+	)
+loop:
+	for p.i < len(p.s) {
+		switch c := p.s[p.i]; {
+		case isHTMLSpace(c):
+			p.i++
+		case c == '>':
+			p.i++
+			break loop
+		case strings.HasPrefix(p.s[p.i:], "/>"):
+			p.i += len("/>")
+			closed = true
+			break loop
+		case c == '/':
+			p.i++
+		default:
+			attrs = append(attrs, p.attr())
+		}
+	}
+	el := nodes.Object{
+		uast.KeyType: nodes.String(htmlElement),
+		uast.KeyPos:  p.pos(start, p.i),
+		"Name":       nodes.String(name),
+		"Attributes": attrs,
+		"Children":   nil,
+	}
+	return el, name, closed
+}
+
+// attr reads a single attribute. The value is stored as-is, without decoding entities.
+func (p *htmlParser) attr() nodes.Object {
+	start := p.i
+	name := p.name()
+	if p.i == start {
+		// not a valid name character, like a quote
+		p.i++
+		name = p.s[start:p.i]
+	}
+	attr := nodes.Object{
+		uast.KeyType: nodes.String(htmlAttribute),
+		"Name":       nodes.String(name),
+		"Value":      nil,
+	}
+	end := p.i
+	j := p.i
+	for j < len(p.s) && isHTMLSpace(p.s[j]) {
+		j++
+	}
+	if j < len(p.s) && p.s[j] == '=' {
+		p.i = j + 1
+		for p.i < len(p.s) && isHTMLSpace(p.s[p.i]) {
+			p.i++
+		}
+		vstart := p.i
+		if p.i < len(p.s) && (p.s[p.i] == '"' || p.s[p.i] == '\'') {
+			q := p.s[p.i : p.i+1]
+			p.i++
+			vstart = p.i
+			vend := p.skipTo(q)
+			attr["Value"] = nodes.String(p.s[vstart:vend])
+		} else {
+			for p.i < len(p.s) && !isHTMLSpace(p.s[p.i]) && p.s[p.i] != '>' {
+				p.i++
+			}
+			attr["Value"] = nodes.String(p.s[vstart:p.i])
+		}
+		end = p.i
+	}
+	attr[uast.KeyPos] = p.pos(start, end)
+	return attr
+}
+
+// indexASCIIFold returns the index of the first occurrence of an ASCII substring in the text, ignoring the case
+// of ASCII letters, or -1 if there is none.
+//
+// Unlike searching in a lowercased copy of the text, the index is valid for texts with non-ASCII characters,
+// which may change their length when lowercased.
+func indexASCIIFold(s, sub string) int {
+loop:
+	for i := 0; i+len(sub) <= len(s); i++ {
+		for j := 0; j < len(sub); j++ {
+			if toLowerASCII(s[i+j]) != toLowerASCII(sub[j]) {
+				continue loop
+			}
+		}
+		return i
+	}
+	return -1
+}
+
+func toLowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isHTMLSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\f':
+		return true
+	}
+	return false
+}
+
+func isHTMLNameEnd(c byte) bool {
+	return isHTMLSpace(c) || c == '>' || c == '/' || c == '=' || c == '"' || c == '\''
+}
@@ -0,0 +1,158 @@
+package normalizer
+
+import (
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+
+	php "github.com/bblfsh/php-driver/driver/normalizer/phpast"
+	"github.com/bblfsh/php-driver/driver/normalizer/phpuast"
+)
+
+// Magic constants that may start a path of an include. The path of a file is not known to the driver,
+// thus those constants are stored as a base of the path.
+const (
+	magicDir  = "__DIR__"
+	magicFile = "__FILE__"
+)
+
+var typeIncludePath = uast.TypeOf(phpuast.IncludePath{})
+
+// includePath is an op that converts a path of an include or require statement and stores the original
+// expression in a variable.
+//
+// String literals are kept as-is, while other expressions are wrapped into an IncludePath node with the path
+// evaluated statically, if possible. On reverse, the evaluated fields must match the expression.
+type includePath struct {
+	vr string
+}
+
+func (op includePath) Kinds() nodes.Kind {
+	return nodes.KindObject
+}
+
+func (op includePath) Check(st *State, n nodes.Node) (bool, error) {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return false, nil
+	}
+	switch uast.TypeOf(obj) {
+	case uast.TypeOf(uast.String{}):
+	case typeIncludePath:
+		expr, ok := obj["Expr"].(nodes.Object)
+		if !ok {
+			return false, nil
+		}
+		if path, ok := newIncludePath(expr); !ok || !nodes.Equal(path, obj) {
+			return false, nil
+		}
+		n = expr
+	default:
+		return false, nil
+	}
+	err := st.SetVar(op.vr, n)
+	return err == nil, err
+}
+
+func (op includePath) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	if n != nil {
+		return nil, ErrUnexpectedValue.New(n)
+	}
+	v, err := st.MustGetVar(op.vr)
+	if err != nil {
+		return nil, err
+	}
+	expr, ok := v.(nodes.Object)
+	if !ok {
+		return nil, ErrUnexpectedType.New(nodes.Object{}, v)
+	}
+	if path, ok := newIncludePath(expr); ok {
+		return path, nil
+	}
+	return expr, nil
+}
+
+// newIncludePath wraps a path expression into an IncludePath node. It returns false for string literals,
+// which are not wrapped.
+//
+// Concatenations of string literals, optionally starting with __DIR__, __FILE__ or dirname(__FILE__), are
+// evaluated, for example `__DIR__ . '/lib/' . 'db.php'` has the base "__DIR__" and the value "/lib/db.php".
+// Other paths are marked as dynamic.
+func newIncludePath(expr nodes.Object) (nodes.Object, bool) {
+	if uast.TypeOf(expr) == uast.TypeOf(uast.String{}) {
+		return nil, false
+	}
+	base, val, ok := foldPath(expr, true)
+	if !ok {
+		base, val = "", ""
+	}
+	path := nodes.Object{
+		uast.KeyType: nodes.String(typeIncludePath),
+		"Base":       nodes.String(base),
+		"Value":      nodes.String(val),
+		"Dynamic":    nodes.Bool(!ok),
+		"Expr":       expr,
+	}
+	if pos, ok := expr[uast.KeyPos]; ok {
+		path[uast.KeyPos] = pos
+	}
+	return path, true
+}
+
+// foldPath evaluates a constant path expression. It returns the magic constant the path starts with,
+// if any, and the rest of the path. Magic constants are only allowed at the start of the path.
+func foldPath(n nodes.Node, first bool) (string, string, bool) {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return "", "", false
+	}
+	switch uast.TypeOf(obj) {
+	case uast.TypeOf(uast.String{}):
+		v, ok := obj["Value"].(nodes.String)
+		return "", string(v), ok
+	case php.ScalarMagicDir:
+		return magicDir, "", first
+	case php.ScalarMagicFile:
+		return magicFile, "", first
+	case php.Concat:
+		base, left, ok := foldPath(obj["left"], first)
+		if !ok {
+			return "", "", false
+		}
+		_, right, ok := foldPath(obj["right"], false)
+		if !ok {
+			return "", "", false
+		}
+		return base, left + right, true
+	case php.FuncCall:
+		if isDirnameOfFile(obj) {
+			return magicDir, "", first
+		}
+	}
+	return "", "", false
+}
+
+// isDirnameOfFile checks if a function call is dirname(__FILE__), which is equivalent to __DIR__.
+func isDirnameOfFile(call nodes.Object) bool {
+	name, ok := call["name"].(nodes.Object)
+	if !ok {
+		return false
+	}
+	if uast.TypeOf(name) == uast.TypeOf(phpuast.AnchoredName{}) && name["Anchor"] == nodes.String("global") {
+		name, _ = name["Name"].(nodes.Object)
+	}
+	if s, ok := qualifiedName(name); !ok || !strings.EqualFold(s, "dirname") {
+		return false
+	}
+	args, _ := call["args"].(nodes.Array)
+	if len(args) != 1 {
+		return false
+	}
+	arg, _ := args[0].(nodes.Object)
+	if arg["unpack"] == nodes.Bool(true) {
+		return false
+	}
+	return uast.TypeOf(arg["value"]) == php.ScalarMagicFile
+}
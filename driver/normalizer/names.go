@@ -0,0 +1,535 @@
+package normalizer
+
+import (
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+
+	"github.com/bblfsh/php-driver/driver/normalizer/phpuast"
+)
+
+// keyFullName is a field of Declaration and ResolvedName nodes that stores a fully qualified name,
+// like "App\Util\foo".
+const keyFullName = "FullName"
+
+var (
+	typeDeclaration  = uast.TypeOf(phpuast.Declaration{})
+	typeResolvedName = uast.TypeOf(phpuast.ResolvedName{})
+)
+
+// nsSeparator is a separator used in qualified names.
+const nsSeparator = `\`
+
+// qualifiedName returns a string representation of a semantic name node.
+func qualifiedName(n nodes.Node) (string, bool) {
+	obj, ok := n.(nodes.Object)
+	if !ok {
+		return "", false
+	}
+	switch uast.TypeOf(obj) {
+	case uast.TypeOf(uast.Identifier{}):
+		name, ok := obj["Name"].(nodes.String)
+		return string(name), ok
+	case uast.TypeOf(uast.QualifiedIdentifier{}):
+		names, _ := obj["Names"].(nodes.Array)
+		parts := make([]string, 0, len(names))
+		for _, n := range names {
+			s, ok := qualifiedName(n)
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, nsSeparator), len(parts) != 0
+	}
+	return "", false
+}
+
+// joinName joins a namespace name with a name of a symbol.
+func joinName(ns, name string) string {
+	if ns == "" {
+		return name
+	}
+	return ns + nsSeparator + name
+}
+
+// qualifyNamespaces wraps functions, classes, interfaces, traits and constants declared in namespaces into
+// Declaration nodes with fully qualified names. Declarations outside of namespaces are wrapped the same way,
+// with names in the global namespace.
+//
+// PHP-Parser nests statements of both braced and unbraced namespaces into Stmt_Namespace, thus both forms
+// are handled the same way.
+var qualifyNamespaces = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	switch uast.TypeOf(obj) {
+	case "Module":
+		return qualifyGlobal(obj)
+	case "Stmt_Namespace":
+	default:
+		return obj, false, nil
+	}
+	ns := ""
+	if name := obj["name"]; name != nil {
+		var ok bool
+		ns, ok = qualifiedName(name)
+		if !ok {
+			return obj, false, nil
+		}
+	}
+	stmts, changed := qualifyDecls(obj["stmts"], ns, false)
+	if !changed {
+		return obj, false, nil
+	}
+	obj = obj.CloneObject()
+	obj["stmts"] = stmts
+	return obj, true, nil
+})
+
+// qualifyGlobal wraps declarations in top-level statements of the module, except for namespaces, which are
+// qualified separately.
+func qualifyGlobal(obj nodes.Object) (nodes.Object, bool, error) {
+	stmts, ok := obj["children"].(nodes.Array)
+	if !ok {
+		return obj, false, nil
+	}
+	var out nodes.Array
+	for i, st := range stmts {
+		if ns, ok := st.(nodes.Object); ok && uast.TypeOf(ns) == "Stmt_Namespace" {
+			continue
+		}
+		st, changed := qualifyDecls(st, "", false)
+		if !changed {
+			continue
+		}
+		if out == nil {
+			out = stmts.CloneList()
+		}
+		out[i] = st
+	}
+	if out == nil {
+		return obj, false, nil
+	}
+	obj = obj.CloneObject()
+	obj["children"] = out
+	return obj, true, nil
+}
+
+// qualifyDecls recursively wraps declarations in a given namespace into Declaration nodes.
+// The member flag is set for class members, which are not qualified by the namespace.
+func qualifyDecls(n nodes.Node, ns string, member bool) (nodes.Node, bool) {
+	switch n := n.(type) {
+	case nodes.Array:
+		var out nodes.Array
+		for i, s := range n {
+			s, changed := qualifyDecls(s, ns, member)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = n.CloneList()
+			}
+			out[i] = s
+		}
+		if out == nil {
+			return n, false
+		}
+		return out, true
+	case nodes.Object:
+		return qualifyDeclObj(n, ns, member)
+	}
+	return n, false
+}
+
+func qualifyDeclObj(obj nodes.Object, ns string, member bool) (nodes.Object, bool) {
+	var (
+		out     nodes.Object
+		members string
+		// fully qualified name of the declaration
+		full string
+	)
+	set := func(k string, v nodes.Node) {
+		if out == nil {
+			out = obj.CloneObject()
+		}
+		out[k] = v
+	}
+	switch uast.TypeOf(obj) {
+	case uast.TypeOf(phpuast.Use{}):
+		// aliases of imported symbols are not declarations
+		return obj, false
+	case uast.TypeOf(uast.Alias{}):
+		if uast.TypeOf(obj["Node"]) == uast.TypeOf(phpuast.ClassLike{}) {
+			if name, ok := qualifiedName(obj["Name"]); ok {
+				full = joinName(ns, name)
+			}
+			members = "Node"
+		}
+	case uast.TypeOf(phpuast.ClassLike{}), uast.TypeOf(phpuast.AnonymousClass{}):
+		members = "Members"
+	case "Const":
+		if name, ok := qualifiedName(obj["name"]); ok {
+			full = joinName(ns, name)
+		}
+	case uast.TypeOf(uast.Block{}), uast.TypeOf(uast.Group{}):
+		// pass the member flag to statements of the class body
+		key := "Statements"
+		if uast.TypeOf(obj) == uast.TypeOf(uast.Group{}) {
+			key = "Nodes"
+		}
+		stmts, changed := qualifyDecls(obj[key], ns, member)
+		if changed {
+			set(key, stmts)
+		}
+		if out == nil {
+			return obj, false
+		}
+		return out, true
+	case uast.TypeOf(uast.FunctionGroup{}):
+		if member {
+			break
+		}
+		group, _ := obj["Nodes"].(nodes.Array)
+		for _, n := range group {
+			alias, ok := n.(nodes.Object)
+			if !ok || uast.TypeOf(alias) != uast.TypeOf(uast.Alias{}) {
+				continue
+			}
+			if name, ok := qualifiedName(alias["Name"]); ok {
+				full = joinName(ns, name)
+				break
+			}
+		}
+	}
+	for k, v := range obj {
+		if out != nil {
+			v = out[k]
+		}
+		v, changed := qualifyDecls(v, ns, k == members)
+		if changed {
+			set(k, v)
+		}
+	}
+	changed := out != nil
+	if !changed {
+		out = obj
+	}
+	if full != "" {
+		decl := nodes.Object{
+			uast.KeyType: nodes.String(typeDeclaration),
+			keyFullName:  nodes.String(full),
+			"Node":       out,
+		}
+		if pos, ok := out[uast.KeyPos]; ok {
+			decl[uast.KeyPos] = pos
+		}
+		return decl, true
+	}
+	return out, changed
+}
+
+// unqualifyNamespaces reverts qualifyNamespaces by replacing Declaration nodes with the declarations they wrap.
+var unqualifyNamespaces = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	if uast.TypeOf(obj) != typeDeclaration {
+		return obj, false, nil
+	}
+	decl, ok := obj["Node"].(nodes.Object)
+	if !ok {
+		return obj, false, ErrUnexpectedType.New(nodes.Object{}, obj["Node"])
+	}
+	return decl, true, nil
+})
+
+// keyFallbackName is a field of ResolvedName nodes that stores a global name that PHP falls back to at runtime
+// if an unqualified function or constant is not defined in the current namespace.
+const keyFallbackName = "FallbackName"
+
+// Kinds of symbols referenced by names. Those match the kinds of imported symbols.
+const (
+	symbolClass    = "class"
+	symbolFunction = "function"
+	symbolConst    = "const"
+)
+
+// nameRefs lists fields of nodes that reference symbols of a specific kind.
+var nameRefs = map[string]map[string]string{
+	"Expr_New":                             {"class": symbolClass},
+	"Expr_StaticCall":                      {"class": symbolClass},
+	"Expr_StaticPropertyFetch":             {"class": symbolClass},
+	"Expr_ClassConstFetch":                 {"class": symbolClass},
+	"Expr_Instanceof":                      {"class": symbolClass},
+	uast.TypeOf(phpuast.AnonymousClass{}):  {"Extends": symbolClass, "Implements": symbolClass},
+	uast.TypeOf(phpuast.ClassLike{}):       {"Extends": symbolClass, "Implements": symbolClass},
+	"Stmt_TraitUse":                        {"traits": symbolClass},
+	uast.TypeOf(phpuast.TraitAlias{}):      {"Trait": symbolClass},
+	uast.TypeOf(phpuast.TraitPrecedence{}): {"Trait": symbolClass, "Excluded": symbolClass},
+	uast.TypeOf(phpuast.Catch{}):           {"Types": symbolClass},
+	uast.TypeOf(phpuast.NullableType{}):    {"Type": symbolClass},
+	uast.TypeOf(phpuast.UnionType{}):       {"Types": symbolClass},
+	uast.TypeOf(phpuast.ByRef{}):           {"Type": symbolClass},
+	uast.TypeOf(uast.Argument{}):           {"Type": symbolClass},
+	"Expr_FuncCall":                        {"name": symbolFunction},
+	"Expr_ConstFetch":                      {"name": symbolConst},
+}
+
+// specialClassNames are class names that are resolved at runtime.
+var specialClassNames = map[string]bool{
+	"self": true, "parent": true, "static": true,
+}
+
+// nameScope stores the current namespace and imported symbols.
+type nameScope struct {
+	ns string
+	// imports maps a kind of symbols to aliases and fully qualified names of imported symbols
+	imports map[string]map[string]string
+}
+
+func newNameScope(ns string) *nameScope {
+	return &nameScope{ns: ns, imports: map[string]map[string]string{
+		symbolClass:    make(map[string]string),
+		symbolFunction: make(map[string]string),
+		symbolConst:    make(map[string]string),
+	}}
+}
+
+// aliasKey returns a key for an import alias. Only constant names are case-sensitive.
+func aliasKey(kind, alias string) string {
+	if kind == symbolConst {
+		return alias
+	}
+	return strings.ToLower(alias)
+}
+
+// addImports records all symbols imported by a Use node.
+func (s *nameScope) addImports(use nodes.Object) {
+	imp, ok := use["Import"].(nodes.Object)
+	if !ok {
+		return
+	}
+	kinds, _ := use["Kinds"].(nodes.Array)
+	prefix := ""
+	if path := imp["Path"]; path != nil {
+		prefix, _ = qualifiedName(path)
+	}
+	names, _ := imp["Names"].(nodes.Array)
+	for i, n := range names {
+		alias, ok := n.(nodes.Object)
+		if !ok || i >= len(kinds) {
+			continue
+		}
+		kind, _ := kinds[i].(nodes.String)
+		m := s.imports[string(kind)]
+		name, ok1 := qualifiedName(alias["Name"])
+		node, ok2 := qualifiedName(alias["Node"])
+		if m == nil || !ok1 || !ok2 {
+			continue
+		}
+		m[aliasKey(string(kind), name)] = joinName(prefix, node)
+	}
+}
+
+// resolve returns a fully qualified name for a name of a given kind.
+// The fallback name is set only for unqualified functions and constants declared in a namespace.
+func (s *nameScope) resolve(kind string, n nodes.Object) (full, fallback string, ok bool) {
+	switch uast.TypeOf(n) {
+	case uast.TypeOf(phpuast.AnchoredName{}):
+		name, ok := qualifiedName(n["Name"])
+		if !ok {
+			return "", "", false
+		}
+		if n["Anchor"] == nodes.String("namespace") {
+			return joinName(s.ns, name), "", true
+		}
+		return name, "", true
+	case uast.TypeOf(uast.QualifiedIdentifier{}):
+		names, _ := n["Names"].(nodes.Array)
+		if len(names) == 0 {
+			return "", "", false
+		}
+		first, ok1 := qualifiedName(names[0])
+		name, ok2 := qualifiedName(n)
+		if !ok1 || !ok2 {
+			return "", "", false
+		}
+		// the first part of a qualified name is always resolved using class and namespace imports
+		if imp, ok := s.imports[symbolClass][aliasKey(symbolClass, first)]; ok {
+			return imp + name[len(first):], "", true
+		}
+		return joinName(s.ns, name), "", true
+	case uast.TypeOf(uast.Identifier{}):
+		name, ok := qualifiedName(n)
+		if !ok {
+			return "", "", false
+		}
+		if kind == symbolClass {
+			if specialClassNames[strings.ToLower(name)] {
+				return "", "", false
+			}
+		}
+		if imp, ok := s.imports[kind][aliasKey(kind, name)]; ok {
+			return imp, "", true
+		}
+		if kind != symbolClass && s.ns != "" {
+			return joinName(s.ns, name), name, true
+		}
+		return joinName(s.ns, name), "", true
+	}
+	return "", "", false
+}
+
+// resolveRef wraps a name node, or each name in a list, into a ResolvedName node with a fully qualified name.
+func (s *nameScope) resolveRef(kind string, n nodes.Node) (nodes.Node, bool) {
+	switch n := n.(type) {
+	case nodes.Array:
+		var out nodes.Array
+		for i, sub := range n {
+			sub, changed := s.resolveRef(kind, sub)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = n.CloneList()
+			}
+			out[i] = sub
+		}
+		if out == nil {
+			return n, false
+		}
+		return out, true
+	case nodes.Object:
+		full, fallback, ok := s.resolve(kind, n)
+		if !ok {
+			return n, false
+		}
+		ref := nodes.Object{
+			uast.KeyType:    nodes.String(typeResolvedName),
+			keyFullName:     nodes.String(full),
+			keyFallbackName: nodes.String(fallback),
+			"Name":          n,
+		}
+		if pos, ok := n[uast.KeyPos]; ok {
+			ref[uast.KeyPos] = pos
+		}
+		return ref, true
+	}
+	return n, false
+}
+
+// walk resolves all names in a subtree. Import statements are recorded in the scope as they appear.
+func (s *nameScope) walk(n nodes.Node) (nodes.Node, bool) {
+	switch n := n.(type) {
+	case nodes.Array:
+		var out nodes.Array
+		for i, sub := range n {
+			sub, changed := s.walk(sub)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = n.CloneList()
+			}
+			out[i] = sub
+		}
+		if out == nil {
+			return n, false
+		}
+		return out, true
+	case nodes.Object:
+		typ := uast.TypeOf(n)
+		if typ == uast.TypeOf(phpuast.Use{}) {
+			s.addImports(n)
+			return n, false
+		}
+		var out nodes.Object
+		refs := nameRefs[typ]
+		for k, v := range n {
+			var changed bool
+			if kind, ok := refs[k]; ok {
+				v, changed = s.resolveRef(kind, v)
+			}
+			v, changed2 := s.walk(v)
+			if changed || changed2 {
+				if out == nil {
+					out = n.CloneObject()
+				}
+				out[k] = v
+			}
+		}
+		if out == nil {
+			return n, false
+		}
+		return out, true
+	}
+	return n, false
+}
+
+// resolveNames wraps all references to classes, functions and constants into ResolvedName nodes with fully
+// qualified names, based on the current namespace and imports.
+var resolveNames = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	if uast.TypeOf(obj) != "Module" {
+		return obj, false, nil
+	}
+	stmts, ok := obj["children"].(nodes.Array)
+	if !ok {
+		return obj, false, nil
+	}
+	var (
+		out    nodes.Array
+		global = newNameScope("")
+	)
+	for i, st := range stmts {
+		var (
+			sub     nodes.Node
+			changed bool
+		)
+		if ns, ok := st.(nodes.Object); ok && uast.TypeOf(ns) == "Stmt_Namespace" {
+			sub, changed = resolveNamespace(ns)
+		} else {
+			sub, changed = global.walk(st)
+		}
+		if !changed {
+			continue
+		}
+		if out == nil {
+			out = stmts.CloneList()
+		}
+		out[i] = sub
+	}
+	if out == nil {
+		return obj, false, nil
+	}
+	obj = obj.CloneObject()
+	obj["children"] = out
+	return obj, true, nil
+})
+
+// resolveNamespace resolves names in the namespace using a separate scope.
+func resolveNamespace(obj nodes.Object) (nodes.Object, bool) {
+	name := ""
+	if n := obj["name"]; n != nil {
+		var ok bool
+		name, ok = qualifiedName(n)
+		if !ok {
+			return obj, false
+		}
+	}
+	stmts, changed := newNameScope(name).walk(obj["stmts"])
+	if !changed {
+		return obj, false
+	}
+	obj = obj.CloneObject()
+	obj["stmts"] = stmts
+	return obj, true
+}
+
+// unresolveNames reverts resolveNames by replacing ResolvedName nodes with the names they wrap.
+var unresolveNames = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	if uast.TypeOf(obj) != typeResolvedName {
+		return obj, false, nil
+	}
+	name, ok := obj["Name"].(nodes.Object)
+	if !ok {
+		return obj, false, ErrUnexpectedType.New(nodes.Object{}, obj["Name"])
+	}
+	return name, true, nil
+})
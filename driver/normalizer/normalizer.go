@@ -1,10 +1,15 @@
 package normalizer
 
 import (
+	"os"
+	"strings"
+
 	"github.com/bblfsh/sdk/v3/uast"
 	"github.com/bblfsh/sdk/v3/uast/nodes"
 	. "github.com/bblfsh/sdk/v3/uast/transformer"
 	"github.com/bblfsh/sdk/v3/uast/transformer/positioner"
+
+	"github.com/bblfsh/php-driver/driver/normalizer/phpuast"
 )
 
 var Preprocess = Transformers([][]Transformer{
@@ -12,11 +17,17 @@ var Preprocess = Transformers([][]Transformer{
 }...)
 
 var Normalize = Transformers([][]Transformer{
+	{dedupNameComments.Func()},
 	{Mappings(PreNormilizers...)},
 	{Mappings(Normalizers...)},
+	{splitDocs},
+	{linkDocParams.Func()},
+	{qualifyNamespaces.Func()},
+	{resolveNames.Func()},
 }...)
 
 var PreprocessCode = []CodeTransformer{
+	inlineHTML{enabled: os.Getenv(envParseHTML) != ""},
 	positioner.FromOffset(),
 }
 
@@ -94,36 +105,45 @@ var Preprocessors = []Mapping{
 }
 
 var PreNormilizers = []Mapping{
+	// "use A, B;" is split into separate statements; each of them keeps the position of the original
+	// statement, which allows to join them back
+	Map(
+		splitStmts{vr: "stmts", typ: "Stmt_Use", list: "uses"},
+		VarKind("stmts", nodes.KindArray),
+	),
+	// the same applies to properties and class constants, like "public $a, $b;" or "const A = 1, B = 2;"
 	Map(
-		splitUse{vr: "uses"},
-		VarKind("uses", nodes.KindArray),
+		splitStmts{vr: "stmts", typ: "Stmt_Property", list: "props"},
+		VarKind("stmts", nodes.KindArray),
+	),
+	Map(
+		splitStmts{vr: "stmts", typ: "Stmt_ClassConst", list: "consts"},
+		VarKind("stmts", nodes.KindArray),
 	),
 }
 
 // Normalizers is the main block of normalization rules to convert native AST to semantic UAST.
 var Normalizers = []Mapping{
-	MapSemantic("Name", uast.Identifier{}, MapObj(
+	// identifiers cannot store comments, thus comments of names are kept in the "comments" field
+	nameWithComments("Name", uast.Identifier{}, MapObj(
 		Fields{
 			{Name: uast.KeyToken, Op: Var("name")},
-			{Name: "comments", Drop: true, Op: Any()}, // FIXME(dennwc): handle comments
 		},
 		Obj{
 			"Name": Var("name"),
 		},
 	)),
-	MapSemantic("Name", uast.Identifier{}, MapObj(
+	nameWithComments("Name", uast.Identifier{}, MapObj(
 		Fields{
 			{Name: "parts", Op: One(Var("name"))},
-			{Name: "comments", Drop: true, Op: Any()}, // FIXME(dennwc): handle comments
 		},
 		Obj{
 			"Name": Var("name"),
 		},
 	)),
-	MapSemantic("Name", uast.QualifiedIdentifier{}, MapObj(
+	nameWithComments("Name", uast.QualifiedIdentifier{}, MapObj(
 		Fields{
 			{Name: "parts", Op: Each("names", Var("name"))},
-			{Name: "comments", Drop: true, Op: Any()}, // FIXME(dennwc): handle comments
 		},
 		Obj{
 			"Names": Each("names", UASTType(uast.Identifier{}, Obj{
@@ -131,6 +151,60 @@ var Normalizers = []Mapping{
 			})),
 		},
 	)),
+	MapObj(
+		JoinObj(Obj{
+			uast.KeyType: Cases("anchor",
+				String("Name_FullyQualified"),
+				String("Name_Relative"),
+			),
+			uast.KeyPos: samePos(),
+			"parts":     Each("names", Var("name")),
+		}, nameComments),
+		JoinObj(UASTType(phpuast.AnchoredName{}, Obj{
+			uast.KeyPos: samePos(),
+			"Anchor": Cases("anchor",
+				String("global"),    // \A\B
+				String("namespace"), // namespace\A\B
+			),
+			"Name": UASTType(uast.QualifiedIdentifier{}, Obj{
+				uast.KeyPos: samePos(),
+				"Names": Each("names", UASTType(uast.Identifier{}, Obj{
+					"Name": Var("name"),
+				})),
+			}),
+		}), nameComments),
+	),
+
+	// true, false and null are case-insensitive and may be fully qualified; the original spelling is kept in the token
+	MapObj(
+		Fields{
+			{Name: uast.KeyType, Op: String("Expr_ConstFetch")},
+			{Name: uast.KeyPos, Op: Var("pos"), Optional: "pos_exists"},
+			{Name: "name", Op: constName("name", "global", Cases("val", String("true"), String("false")))},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: uast.KeyType, Op: String(uast.TypeOf(uast.Bool{}))},
+			{Name: uast.KeyPos, Op: Var("pos"), Optional: "pos_exists"},
+			{Name: uast.KeyToken, Op: constToken("name", "global")},
+			{Name: "Value", Op: Cases("val", Bool(true), Bool(false))},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	),
+	MapObj(
+		Fields{
+			{Name: uast.KeyType, Op: String("Expr_ConstFetch")},
+			{Name: uast.KeyPos, Op: Var("pos"), Optional: "pos_exists"},
+			{Name: "name", Op: constName("name", "global", String("null"))},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: uast.KeyType, Op: String(uast.TypeOf(phpuast.Null{}))},
+			{Name: uast.KeyPos, Op: Var("pos"), Optional: "pos_exists"},
+			{Name: uast.KeyToken, Op: constToken("name", "global")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	),
 
 	MapSemantic("Scalar_String", uast.String{}, MapObj(
 		Obj{
@@ -149,17 +223,26 @@ var Normalizers = []Mapping{
 		},
 	)),
 
+	// heredoc and nowdoc literals keep the doc label in the format, like "heredoc:EOT"; the indentation
+	// of the closing marker of flexible heredoc and nowdoc literals is kept as well, like "heredoc:  EOT"
 	MapSemantic("Scalar_String", uast.String{}, MapObj(
 		Obj{
 			"value": Var("val"),
-			"attributes": Obj{
-				"kind":     Int(3),
-				"docLabel": AnyVal(nil), // TODO: store it
+			"attributes": Fields{
+				{Name: "kind", Op: Cases("kind",
+					Int(3), // heredoc
+					Int(4), // nowdoc
+				)},
+				{Name: "docLabel", Op: Var("label")},
+				{Name: "docIndentation", Op: Var("indent"), Optional: "indent_exists"},
 			},
 		},
 		Obj{
-			"Value":  Var("val"),
-			"Format": String("raw_custom"),
+			"Value": Var("val"),
+			"Format": Cases("kind",
+				opPrefix{prefix: "heredoc:", op: docLabel()},
+				opPrefix{prefix: "nowdoc:", op: docLabel()},
+			),
 		},
 	)),
 	MapSemantic("Scalar_String", uast.String{}, MapObj(
@@ -171,6 +254,36 @@ var Normalizers = []Mapping{
 			"Format": String(""),
 		},
 	)),
+	// interpolated strings are represented as a template listing literal parts and embedded expressions in order;
+	// the format is the same as for uast.String
+	MapSemantic("Scalar_Encapsed", phpuast.StringTemplate{}, MapObj(
+		Fields{
+			{Name: "attributes", Op: Obj{"kind": Int(2)}},
+			{Name: "parts", Op: Var("parts")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Format", Op: String("")},
+			{Name: "Parts", Op: Var("parts")},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+	MapSemantic("Scalar_Encapsed", phpuast.StringTemplate{}, MapObj(
+		Fields{
+			{Name: "attributes", Op: Fields{
+				{Name: "kind", Op: Int(3)}, // heredoc
+				{Name: "docLabel", Op: Var("label")},
+				{Name: "docIndentation", Op: Var("indent"), Optional: "indent_exists"},
+			}},
+			{Name: "parts", Op: Var("parts")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Format", Op: opPrefix{prefix: "heredoc:", op: docLabel()}},
+			{Name: "Parts", Op: Var("parts")},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
 	MapSemantic("Scalar_EncapsedStringPart", uast.String{}, MapObj(
 		Obj{
 			"value": Var("val"),
@@ -198,11 +311,350 @@ var Normalizers = []Mapping{
 		},
 		CommentNode(true, "text", nil),
 	)),
-	MapSemantic("Comment_Doc", uast.Comment{}, MapObj(
-		Obj{ // FIXME: doc should write additional flag
-			"text": CommentText([2]string{"/**", "*/"}, "text"),
+	// doc comments are converted to uast.Comment as well, but are marked to distinguish them from
+	// block comments on reverse; the parsed PHPDoc is added next to them by splitDocs
+	MapObj(
+		Obj{
+			uast.KeyType: String("Comment_Doc"),
+			uast.KeyPos:  Var("pos"),
+			"text":       CommentText([2]string{"/**", "*/"}, "text"),
+		},
+		Obj{
+			uast.KeyType: String(docComment),
+			"Comment":    CommentNode(true, "text", Var("pos")),
+		},
+	),
+
+	// properties and class constants; statements with multiple declarations are already split
+	MapObj(
+		Fields{
+			{Name: uast.KeyType, Op: String("Stmt_Property")},
+			{Name: uast.KeyPos, Op: Var("pos")},
+			{Name: "flags", Op: Var("flags")},
+			flagsAlias,
+			{Name: "props", Op: One(Fields{
+				{Name: uast.KeyType, Op: String("Stmt_PropertyProperty")},
+				{Name: uast.KeyPos, Op: Var("decl_pos")},
+				{Name: "name", Op: Var("name")},
+				{Name: "default", Op: Var("default")},
+				{Name: "comments", Op: Var("name_comments"), Optional: "name_comments_exists"},
+			})},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		field("property"),
+	),
+	MapObj(
+		Fields{
+			{Name: uast.KeyType, Op: String("Stmt_ClassConst")},
+			{Name: uast.KeyPos, Op: Var("pos")},
+			{Name: "flags", Op: Var("flags")},
+			{Name: "consts", Op: One(Fields{
+				{Name: uast.KeyType, Op: String("Const")},
+				{Name: uast.KeyPos, Op: Var("decl_pos")},
+				{Name: "name", Op: Var("name")},
+				{Name: "value", Op: Var("default")},
+				{Name: "comments", Op: Var("name_comments"), Optional: "name_comments_exists"},
+			})},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		field("const"),
+	),
+
+	// trait adaptation rules, like "A::m insteadof B;" and "A::m as protected n;"
+	MapSemantic("Stmt_TraitUseAdaptation_Precedence", phpuast.TraitPrecedence{}, MapObj(
+		Fields{
+			{Name: "trait", Op: Var("trait")},
+			{Name: "method", Op: Var("method")},
+			{Name: "insteadof", Op: Var("excluded")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Trait", Op: Var("trait")},
+			{Name: "Method", Op: UASTType(uast.Identifier{}, Obj{
+				"Name": Var("method"),
+			})},
+			{Name: "Excluded", Op: Var("excluded")},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+	MapSemantic("Stmt_TraitUseAdaptation_Alias", phpuast.TraitAlias{}, MapObj(
+		Fields{
+			{Name: "trait", Op: Var("trait")},
+			{Name: "method", Op: Var("method")},
+			{Name: "newName", Op: Cases("name_case",
+				Is(nil),
+				Var("name"),
+			)},
+			{Name: "newModifier", Op: Cases("vis",
+				Is(nil),
+				Int(1), // public
+				Int(2), // protected
+				Int(4), // private
+			)},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Trait", Op: Var("trait")},
+			{Name: "Method", Op: UASTType(uast.Identifier{}, Obj{
+				"Name": Var("method"),
+			})},
+			{Name: "Name", Op: Cases("name_case",
+				Is(nil),
+				UASTType(uast.Identifier{}, Obj{
+					"Name": Var("name"),
+				}),
+			)},
+			{Name: "Visibility", Op: Cases("vis",
+				String(""),
+				String("public"),
+				String("protected"),
+				String("private"),
+			)},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+
+	// type declarations are mapped to an Alias that names a ClassLike node;
+	// anonymous classes have no name and are handled with the "new" expression below
+	MapSemantic("Stmt_Class", uast.Group{}, MapObj(
+		Fields{
+			{Name: "name", Op: Check(NotNil(), Var("name"))},
+			{Name: "flags", Op: Var("flags")},
+			flagsAlias,
+			{Name: "extends", Op: Cases("extends_case",
+				Is(nil),
+				Var("extends"),
+			)},
+			{Name: "implements", Op: Var("implements")},
+			{Name: "stmts", Op: Var("members")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Obj{
+			"Nodes": withComments("comments", Arr(
+				modifiers("flags"),
+				typeAlias("class",
+					Cases("extends_case",
+						Arr(),
+						Arr(Var("extends")),
+					),
+					Var("implements"),
+				),
+			)),
+		},
+	)),
+	MapSemantic("Stmt_Interface", uast.Group{}, MapObj(
+		Fields{
+			{Name: "name", Op: Var("name")},
+			{Name: "extends", Op: Var("extends")},
+			{Name: "stmts", Op: Var("members")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Obj{
+			"Nodes": withComments("comments", Arr(
+				typeAlias("interface", Var("extends"), Arr()),
+			)),
+		},
+	)),
+	MapSemantic("Stmt_Trait", uast.Group{}, MapObj(
+		Fields{
+			{Name: "name", Op: Var("name")},
+			{Name: "stmts", Op: Var("members")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Obj{
+			"Nodes": withComments("comments", Arr(
+				typeAlias("trait", Arr(), Arr()),
+			)),
+		},
+	)),
+	// array literals; the kind of the array is derived from the items
+	MapSemantic("Expr_ArrayItem", phpuast.ArrayItem{}, MapObj(
+		Fields{
+			{Name: "key", Op: Var("key")},
+			{Name: "value", Op: Var("value")},
+			{Name: "byRef", Op: Var("byref")},
+			// only set by newer parser versions
+			{Name: "unpack", Op: Var("unpack"), Optional: "unpack_exists"},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Key", Op: Var("key")},
+			{Name: "Value", Op: Var("value")},
+			{Name: "ByRef", Op: Var("byref")},
+			{Name: "Unpack", Op: Var("unpack"), Optional: "unpack_exists"},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+	MapSemantic("Expr_Array", phpuast.Array{}, MapObj(
+		Fields{
+			{Name: "items", Op: Var("items")},
+			{Name: "attributes", Op: Obj{"kind": Cases("kind",
+				Int(1), // array()
+				Int(2), // []
+			)}},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Items", Op: Var("items")},
+			{Name: "Kind", Op: arrayKind{vr: "items"}},
+			{Name: "Short", Op: Cases("kind",
+				Bool(false),
+				Bool(true),
+			)},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+
+	MapSemantic("Stmt_Global", phpuast.Global{}, MapObj(
+		Fields{
+			{Name: "vars", Op: Var("vars")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Vars", Op: Var("vars")},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+	MapSemantic("Stmt_StaticVar", phpuast.StaticVar{}, MapObj(
+		Fields{
+			{Name: "name", Op: Var("name")},
+			{Name: "default", Op: Var("default")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Name", Op: Var("name")},
+			{Name: "Default", Op: Var("default")},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+	MapSemantic("Stmt_Static", phpuast.Static{}, MapObj(
+		Fields{
+			{Name: "vars", Op: Var("vars")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Vars", Op: Var("vars")},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+
+	MapSemantic("Stmt_Catch", phpuast.Catch{}, MapObj(
+		Fields{
+			{Name: "types", Op: Var("types")},
+			{Name: "var", Op: Cases("var_case",
+				Is(nil),
+				Var("var"),
+			)},
+			{Name: "stmts", Op: Var("body")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Types", Op: Var("types")},
+			{Name: "Var", Op: Cases("var_case",
+				Is(nil),
+				UASTType(uast.Identifier{}, Obj{
+					"Name": Var("var"),
+				}),
+			)},
+			{Name: "Body", Op: UASTType(uast.Block{}, Obj{
+				"Statements": Var("body"),
+			})},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+	// the finally clause is mapped to a block with the position of the clause; blocks cannot store comments,
+	// thus comments of the finally clause are kept in the Try node
+	MapSemantic("Stmt_TryCatch", phpuast.Try{}, MapObj(
+		Fields{
+			{Name: "stmts", Op: Var("body")},
+			{Name: "catches", Op: Var("catches")},
+			{Name: "finally", Op: Cases("finally_case",
+				// there are no comments without the finally clause
+				Opt("finally_comments_exists", Is(nil)),
+				Fields{
+					{Name: uast.KeyType, Op: String("Stmt_Finally")},
+					{Name: uast.KeyPos, Op: Var("finally_pos")},
+					{Name: "stmts", Op: Var("finally")},
+					{Name: "comments", Op: Var("finally_comments"), Optional: "finally_comments_exists"},
+				},
+			)},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Body", Op: UASTType(uast.Block{}, Obj{
+				"Statements": Var("body"),
+			})},
+			{Name: "Catches", Op: Var("catches")},
+			{Name: "Finally", Op: Cases("finally_case",
+				Is(nil),
+				UASTType(uast.Block{}, Obj{
+					uast.KeyPos:  Var("finally_pos"),
+					"Statements": Var("finally"),
+				}),
+			)},
+			{Name: "FinallyComments", Op: Var("finally_comments"), Optional: "finally_comments_exists"},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+
+	// destructuring assignments; nested lists are converted by the listPattern op
+	MapSemantic("Expr_Assign", phpuast.Destructuring{}, MapObj(
+		Fields{
+			{Name: "var", Op: listPattern{vr: "pattern"}},
+			{Name: "expr", Op: Var("value")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Pattern", Op: Var("pattern")},
+			{Name: "Value", Op: Var("value")},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+
+	// foreach loops may destructure values as well
+	MapPart("root", ObjMap{
+		uast.KeyType: String("Stmt_Foreach"),
+		"valueVar": Map(
+			listPattern{vr: "pattern"},
+			Check(HasType(phpuast.ListPattern{}), Var("pattern")),
+		),
+	}),
+
+	// anonymous classes are declared and instantiated in the same expression
+	MapSemantic("Expr_New", phpuast.New{}, MapObj(
+		Fields{
+			{Name: "class", Op: Fields{
+				{Name: uast.KeyType, Op: String("Stmt_Class")},
+				{Name: uast.KeyPos, Op: Var("class_pos")},
+				{Name: "name", Op: Is(nil)},
+				{Name: "flags", Op: Var("flags")},
+				flagsAlias,
+				{Name: "extends", Op: Cases("extends_case",
+					Is(nil),
+					Var("extends"),
+				)},
+				{Name: "implements", Op: Var("implements")},
+				{Name: "stmts", Op: Var("members")},
+				{Name: "comments", Op: Var("class_comments"), Optional: "class_comments_exists"},
+			}},
+			{Name: "args", Op: Var("args")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Type", Op: UASTType(phpuast.AnonymousClass{}, Fields{
+				{Name: uast.KeyPos, Op: Var("class_pos")},
+				{Name: "Modifiers", Op: modifiers("flags")},
+				{Name: "Extends", Op: Cases("extends_case",
+					Arr(),
+					Arr(Var("extends")),
+				)},
+				{Name: "Implements", Op: Var("implements")},
+				{Name: "Members", Op: Var("members")},
+				{Name: "Comments", Op: Var("class_comments"), Optional: "class_comments_exists"},
+			})},
+			{Name: "Arguments", Op: Var("args")},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
 		},
-		CommentNode(true, "text", nil),
 	)),
 
 	convertBlock("Stmt_If", ""),
@@ -211,69 +663,39 @@ var Normalizers = []Mapping{
 	convertBlock("Stmt_For", ""),
 	convertBlock("Stmt_Foreach", ""),
 	convertBlock("Stmt_While", ""),
-	convertBlock("Stmt_Class", ""),
 	convertBlock("Stmt_Do", ""),
-	convertBlock("Stmt_ClassMethod", ""),
-	convertBlock("Expr_Closure", ""),
 	convertBlock("Stmt_Namespace", ""),
-	convertBlock("Stmt_Interface", ""),
-	convertBlock("Stmt_Catch", ""),
-	convertBlock("Stmt_Finally", ""),
-	convertBlock("Stmt_TryCatch", ""),
 	convertBlock("Stmt_Case", ""),
 	convertBlock("Stmt_Switch", ""),
-	convertBlock("Stmt_Trait", ""),
 	convertBlock("Stmt_Declare", "body_stmts"),
 
-	MapSemantic("Expr_Include", uast.RuntimeReImport{}, MapObj(
-		Obj{
-			"expr": Var("path"),
-			"type": Cases("typ",
-				Int(1), // include
-				Int(3), // require
-			),
-		},
-		Obj{
-			"Path": Var("path"),
-			"All": Cases("typ",
-				Bool(true), // include
-				Bool(true), // require
-			),
-		},
-	)),
-
-	MapSemantic("Expr_Include", uast.RuntimeImport{}, MapObj(
-		Obj{
-			"expr": Var("path"),
-			"type": Cases("typ",
-				Int(2), // include_once
-				Int(4), // require_once
-			),
-		},
-		Obj{
-			"Path": Var("path"),
-			"All": Cases("typ",
-				Bool(true), // include
-				Bool(true), // require
-			),
-		},
-	)),
+	includeExpr(uast.RuntimeReImport{}, 1, 3), // include and require
+	includeExpr(uast.RuntimeImport{}, 2, 4),   // include_once and require_once
 
-	MapSemantic("Stmt_GroupUse", uast.RuntimeImport{}, MapObj(
-		Obj{
-			"prefix": Var("path"),
-			"type":   Int(0),
-			"uses": Each("names", Obj{
+	// group use with a kind set for each imported symbol, like "use A\{function b, const C};"
+	MapObj(
+		Fields{
+			{Name: uast.KeyType, Op: String("Stmt_GroupUse")},
+			{Name: uast.KeyPos, Op: Var("pos")},
+			{Name: "prefix", Op: Var("path")},
+			{Name: "type", Op: Int(0)},
+			{Name: "uses", Op: Each("names", Obj{
 				uast.KeyType: String("Stmt_UseUse"),
 				uast.KeyPos:  Var("name_pos"),
-				"type":       Int(1),
-				"alias":      Var("alias"),
-				"name":       Var("name"),
-			}),
+				"type": Cases("kind",
+					Int(1), // use
+					Int(2), // use function
+					Int(3), // use const
+				),
+				"alias": Var("alias"),
+				"name":  Var("name"),
+			})},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
 		},
-		Obj{
-			"Path": Var("path"),
-			"All":  Bool(false),
+		useStmt(true, UASTType(uast.RuntimeImport{}, Obj{
+			uast.KeyPos: Var("pos"),
+			"Path":      Var("path"),
+			"All":       Bool(false),
 			"Names": Each("names", UASTType(uast.Alias{}, Obj{
 				uast.KeyPos: Var("name_pos"),
 				"Name": UASTType(uast.Identifier{}, Obj{
@@ -281,17 +703,20 @@ var Normalizers = []Mapping{
 				}),
 				"Node": Var("name"),
 			})),
-		},
-	)),
-	MapSemantic("Stmt_Use", uast.RuntimeImport{}, MapObj(
-		Obj{
-			"type": Cases("typ",
-				// TODO: do we care?
+		}), opEachIn{vr: "names", op: importKind("kind")}),
+	),
+	groupUse(2, "function"), // use function A\{b, c};
+	groupUse(3, "const"),    // use const A\{B, C};
+	MapObj(
+		Fields{
+			{Name: uast.KeyType, Op: String("Stmt_Use")},
+			{Name: uast.KeyPos, Op: Var("pos")},
+			{Name: "type", Op: Cases("typ",
 				Int(1), // use
 				Int(2), // use function
 				Int(3), // use const
-			),
-			"uses": One(Obj{
+			)},
+			{Name: "uses", Op: One(Obj{
 				uast.KeyType: String("Stmt_UseUse"),
 				uast.KeyPos:  Var("name_pos"),
 				"type":       Int(0),
@@ -302,35 +727,58 @@ var Normalizers = []Mapping{
 						"Names": Append(Var("path_pref"), One(Var("name"))),
 					}),
 				),
-			}),
+			})},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
 		},
-		CasesObj("name_case",
-			// common
-			Obj{
-				"All": Cases("typ",
-					Bool(false), // use
-					Bool(false), // use function
-					Bool(false), // use const
-				),
-				"Names": One(UASTType(uast.Alias{}, Obj{
-					uast.KeyPos: Var("name_pos"),
-					"Name": UASTType(uast.Identifier{}, Obj{
-						"Name": Var("alias"),
-					}),
-					"Node": Var("name"),
-				})),
-			},
-			Objs{
-				{
-					"Path": Is(nil),
+		useStmt(false, UASTType(uast.RuntimeImport{}, JoinObj(
+			Obj{uast.KeyPos: Var("pos")},
+			CasesObj("name_case",
+				// common
+				Obj{
+					"All": Bool(false),
+					"Names": One(UASTType(uast.Alias{}, Obj{
+						uast.KeyPos: Var("name_pos"),
+						"Name": UASTType(uast.Identifier{}, Obj{
+							"Name": Var("alias"),
+						}),
+						"Node": Var("name"),
+					})),
 				},
-				{
-					"Path": UASTTypePart("path", uast.QualifiedIdentifier{}, Obj{
-						"Names": Var("path_pref"),
-					}),
+				Objs{
+					{
+						"Path": Is(nil),
+					},
+					{
+						"Path": UASTTypePart("path", uast.QualifiedIdentifier{}, Obj{
+							"Names": Var("path_pref"),
+						}),
+					},
 				},
-			},
-		),
+			),
+		)), One(importKind("typ"))),
+	),
+
+	// type expressions, like "?int" or "A|B"
+	MapSemantic("NullableType", phpuast.NullableType{}, MapObj(
+		Fields{
+			{Name: "type", Op: typeCaseLeft("typ")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Type", Op: typeCaseRight("typ")},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+	// union types are not supported by the current version of the parser; the mapping follows the newer AST
+	MapSemantic("UnionType", phpuast.UnionType{}, MapObj(
+		Fields{
+			{Name: "types", Op: Each("types", typeCaseLeft("typ"))},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Types", Op: Each("types", typeCaseRight("typ"))},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
 	)),
 
 	MapSemantic("Param", uast.Argument{}, MapObj(
@@ -348,10 +796,9 @@ var Normalizers = []Mapping{
 			"Name": Var("name"),
 			"Type": Cases("by_ref",
 				typeCaseRight("typ"),
-				Obj{
-					uast.KeyType: String("ByRef"),
-					"Type":       typeCaseRight("typ"),
-				},
+				UASTType(phpuast.ByRef{}, Obj{
+					"Type": typeCaseRight("typ"),
+				}),
 			),
 			"Init":     Var("init"),
 			"Variadic": Var("variadic"),
@@ -367,53 +814,337 @@ var Normalizers = []Mapping{
 			{Name: "params", Op: Var("params")},
 			{Name: "returnType", Op: typeCaseLeft("return")},
 			{Name: "stmts", Op: Var("body")},
-			{Name: "comments", Drop: true, Op: Any()}, // FIXME(dennwc): handle comments
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
 		},
 		Obj{
-			"Nodes": Arr(
+			"Nodes": withComments("comments", Arr(
 				UASTType(uast.Alias{}, Obj{
 					"Name": Var("name"),
 					"Node": UASTType(uast.Function{}, Obj{
-						"Type": UASTType(uast.FunctionType{}, Obj{
-							"Arguments": Var("params"),
-							"Returns": One(UASTType(uast.Argument{}, Obj{
-								"Type": Cases("by_ref",
-									// by val
-									typeCaseRight("return"),
-									// by ref
-									Obj{
-										uast.KeyType: String("ByRef"),
-										"Type":       typeCaseRight("return"),
-									},
-								),
-							})),
-						}),
+						"Type": funcType(),
 						"Body": UASTType(uast.Block{}, Obj{
 							"Statements": Var("body"),
 						}),
 					}),
 				}),
-			),
+			)),
 		},
 	)),
+	MapSemantic("Stmt_ClassMethod", uast.FunctionGroup{}, MapObj(
+		Fields{
+			{Name: "byRef", Op: Cases("by_ref",
+				Bool(false),
+				Bool(true),
+			)},
+			{Name: "flags", Op: Var("flags")},
+			flagsAlias,
+			{Name: "name", Op: Var("name")},
+			{Name: "params", Op: Var("params")},
+			{Name: "returnType", Op: typeCaseLeft("return")},
+			{Name: "stmts", Op: Cases("body_case",
+				// abstract and interface methods
+				Is(nil),
+				Var("body"),
+			)},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Obj{
+			"Nodes": withComments("comments", Arr(
+				modifiers("flags"),
+				UASTType(uast.Alias{}, Obj{
+					"Name": Var("name"),
+					"Node": UASTType(uast.Function{}, Obj{
+						"Type": funcType(),
+						"Body": Cases("body_case",
+							Is(nil),
+							UASTType(uast.Block{}, Obj{
+								"Statements": Var("body"),
+							}),
+						),
+					}),
+				}),
+			)),
+		},
+	)),
+	MapSemantic("Expr_ClosureUse", phpuast.Capture{}, MapObj(
+		Fields{
+			{Name: "byRef", Op: Var("by_ref")},
+			{Name: "var", Op: Var("name")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		Fields{
+			{Name: "Name", Op: UASTType(uast.Identifier{}, Obj{
+				"Name": Var("name"),
+			})},
+			{Name: "ByRef", Op: Var("by_ref")},
+			{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+	)),
+	MapObj(
+		Fields{
+			{Name: uast.KeyType, Op: String("Expr_Closure")},
+			{Name: uast.KeyPos, Op: Var("pos")},
+			{Name: "byRef", Op: Cases("by_ref",
+				Bool(false),
+				Bool(true),
+			)},
+			{Name: "static", Op: Var("static")},
+			{Name: "params", Op: Var("params")},
+			{Name: "returnType", Op: typeCaseLeft("return")},
+			{Name: "stmts", Op: Var("body")},
+			{Name: "uses", Op: Var("uses")},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		UASTType(uast.FunctionGroup{}, Obj{
+			uast.KeyPos: Var("pos"),
+			// closures are anonymous, thus there is no Alias in the group;
+			// captured variables are listed in the Closure node that precedes the function
+			"Nodes": withComments("comments", Arr(
+				UASTType(phpuast.Closure{}, Obj{
+					uast.KeyPos: Var("pos"),
+					"Static":    Var("static"),
+					"Captures":  Var("uses"),
+				}),
+				UASTType(uast.Function{}, Obj{
+					"Type": funcType(),
+					"Body": UASTType(uast.Block{}, Obj{
+						"Statements": Var("body"),
+					}),
+				}),
+			)),
+		}),
+	),
 }
 
-func typeCaseLeft(vr string) Op {
-	return Cases(vr+"_case",
-		Is(nil),
-		VarKind(vr, nodes.KindString),
-		VarKind(vr, nodes.KindObject|nodes.KindArray),
-	)
-}
-
-func typeCaseRight(vr string) Op {
+// flagsAlias matches the "type" field of classes, properties and methods. PHP-Parser keeps it as a legacy alias
+// of the "flags" field, thus both fields always have the same value.
+var flagsAlias = Field{Name: "type", Op: Var("flags")}
+
+// withComments prepends an optional list of comments to an array of nodes.
+// The comments field is expected to be marked with "<vr>_exists" optional variable.
+func withComments(vr string, arr Op) Op {
+	return opWithComments{vr: vr, exists: vr + "_exists", op: arr}
+}
+
+// nameComments is an optional "comments" field of a name.
+var nameComments = Fields{
+	{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+}
+
+// nameWithComments is like MapSemantic, but keeps comments of the name in the "comments" field, since identifiers
+// cannot store them.
+func nameWithComments(nativeType string, semType interface{}, m ObjMapping) ObjMapping {
+	src, dst := MapSemantic(nativeType, semType, m).ObjMapping()
+	return MapObj(JoinObj(src, nameComments), JoinObj(dst, nameComments))
+}
+
+// importKind maps the type of the use statement to the kind of imported symbols.
+func importKind(vr string) Op {
+	return Cases(vr,
+		String("class"),    // use
+		String("function"), // use function
+		String("const"),    // use const
+	)
+}
+
+// useStmt wraps an import node into a Use node with the kinds of imported symbols. The group flag is required
+// to distinguish "use function A\{b};" from "use function A\b;" on reverse.
+//
+// The import is checked before the kinds, since kinds of group use statements are stored in states of names.
+// Comments of the statement are taken from the optional "comments" variable.
+func useStmt(group bool, imp ObjectOp, kinds Op) ObjectOp {
+	return UASTType(phpuast.Use{}, Fields{
+		{Name: uast.KeyPos, Op: Var("pos")},
+		{Name: "Group", Op: Bool(group)},
+		{Name: "Import", Op: imp},
+		{Name: "Kinds", Op: kinds},
+		{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+	})
+}
+
+// field constructs a declaration of a class property or a class constant from "name", "default", "flags"
+// and optional "comments" and "name_comments" variables. The position of the declaration statement is stored
+// in Modifiers.
+func field(kind string) ObjectOp {
+	return UASTType(phpuast.Field{}, Fields{
+		{Name: uast.KeyPos, Op: Var("decl_pos")},
+		{Name: "Kind", Op: String(kind)},
+		{Name: "Name", Op: Var("name")},
+		{Name: "Default", Op: Var("default")},
+		{Name: "Modifiers", Op: UASTType(phpuast.Modifiers{}, Obj{
+			uast.KeyPos: Var("pos"),
+			"Flags":     opFlags{op: Var("flags")},
+		})},
+		{Name: "Visibility", Op: memberFlag{vr: "flags", part: "visibility"}},
+		{Name: "Static", Op: memberFlag{vr: "flags", part: "static"}},
+		{Name: "NameComments", Op: Var("name_comments"), Optional: "name_comments_exists"},
+		{Name: "Comments", Op: Var("comments"), Optional: "comments_exists"},
+	})
+}
+
+// typeAlias constructs an Alias that names a class, interface or trait declaration.
+func typeAlias(kind string, extends, implements Op) ObjectOp {
+	return UASTType(uast.Alias{}, Obj{
+		"Name": Var("name"),
+		"Node": UASTType(phpuast.ClassLike{}, Obj{
+			"Kind":       String(kind),
+			"Extends":    extends,
+			"Implements": implements,
+			"Members":    Var("members"),
+		}),
+	})
+}
+
+// groupUse maps a group use statement with the kind set for the whole group, like "use function A\{b, c};".
+func groupUse(typ int, kind string) Mapping {
+	return MapObj(
+		Fields{
+			{Name: uast.KeyType, Op: String("Stmt_GroupUse")},
+			{Name: uast.KeyPos, Op: Var("pos")},
+			{Name: "prefix", Op: Var("path")},
+			{Name: "type", Op: Int(typ)},
+			{Name: "uses", Op: Each("names", Obj{
+				uast.KeyType: String("Stmt_UseUse"),
+				uast.KeyPos:  Var("name_pos"),
+				"type":       Int(0),
+				"alias":      Var("alias"),
+				"name":       Var("name"),
+			})},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		useStmt(true, UASTType(uast.RuntimeImport{}, Obj{
+			uast.KeyPos: Var("pos"),
+			"Path":      Var("path"),
+			"All":       Bool(false),
+			"Names": Each("names", UASTType(uast.Alias{}, Obj{
+				uast.KeyPos: Var("name_pos"),
+				"Name": UASTType(uast.Identifier{}, Obj{
+					"Name": Var("alias"),
+				}),
+				"Node": Var("name"),
+			})),
+		}), opEachIn{vr: "names", op: String(kind)}),
+	)
+}
+
+// includeExpr maps include and require expressions with given types to an import node of a given type.
+// Imports cannot store comments, thus comments are kept in the "comments" field, like in the native AST.
+func includeExpr(imp interface{}, include, require int) Mapping {
+	return MapObj(
+		Fields{
+			{Name: uast.KeyType, Op: String("Expr_Include")},
+			{Name: uast.KeyPos, Op: Var("pos")},
+			{Name: "expr", Op: Var("path")},
+			{Name: "type", Op: Cases("typ",
+				Int(include),
+				Int(require),
+			)},
+			{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+		},
+		JoinObj(
+			UASTType(imp, Obj{
+				uast.KeyPos: Var("pos"),
+				"Path":      includePath{vr: "path"},
+				"All": Cases("typ",
+					Bool(true), // include
+					Bool(true), // require
+				),
+			}),
+			Fields{
+				{Name: "comments", Op: Var("comments"), Optional: "comments_exists"},
+			},
+		),
+	)
+}
+
+// funcType constructs a function signature from "params", "by_ref" and "return" variables.
+func funcType() ObjectOp {
+	return UASTType(uast.FunctionType{}, Obj{
+		"Arguments": Var("params"),
+		"Returns": One(UASTType(uast.Argument{}, Obj{
+			"Type": Cases("by_ref",
+				// by val
+				typeCaseRight("return"),
+				// by ref
+				UASTType(phpuast.ByRef{}, Obj{
+					"Type": typeCaseRight("return"),
+				}),
+			),
+		})),
+	})
+}
+
+// samePos matches positions that are shared between a node and its child.
+func samePos() ObjectOp {
+	return UASTType(uast.Positions{}, Obj{
+		uast.KeyStart: Var(uast.KeyStart),
+		uast.KeyEnd:   Var(uast.KeyEnd),
+	})
+}
+
+// constName matches an unqualified or a fully qualified constant name, like "true" or "\true", with the same
+// position as the parent node. The lowercased name is checked with the lower operation. The anchor variable
+// selects the form of the name and should be passed to constToken.
+func constName(vr, anchor string, lower Op) Op {
+	name := opLower{orig: Var(vr), lower: lower}
+	return Cases(anchor,
+		UASTType(phpuast.AnchoredName{}, Fields{
+			{Name: uast.KeyPos, Op: Var("pos"), Optional: "pos_exists"},
+			{Name: "Anchor", Op: String("global")},
+			{Name: "Name", Op: UASTType(uast.QualifiedIdentifier{}, Fields{
+				{Name: uast.KeyPos, Op: Var("pos"), Optional: "pos_exists"},
+				{Name: "Names", Op: One(UASTType(uast.Identifier{}, Obj{
+					"Name": name,
+				}))},
+			})},
+		}),
+		UASTType(uast.Identifier{}, Fields{
+			{Name: uast.KeyPos, Op: Var("pos"), Optional: "pos_exists"},
+			{Name: "Name", Op: name},
+		}),
+	)
+}
+
+// constToken stores the spelling of a constant name matched by constName, including the leading backslash
+// of fully qualified names.
+func constToken(vr, anchor string) Op {
+	return Cases(anchor,
+		opPrefix{prefix: nsSeparator, op: Var(vr)},
+		Var(vr),
+	)
+}
+
+// modifiers creates a Modifiers node that lists keywords encoded in the flags bitmask.
+func modifiers(vr string) ObjectOp {
+	return UASTType(phpuast.Modifiers{}, Obj{
+		"Flags": opFlags{op: Var(vr)},
+	})
+}
+
+// typeCaseLeft matches a native type expression: no type, a built-in type name or a type node.
+func typeCaseLeft(vr string) Op {
 	return Cases(vr+"_case",
 		Is(nil),
-		UASTType(uast.Identifier{}, Obj{"Name": Var(vr)}),
+		VarKind(vr, nodes.KindString),
 		VarKind(vr, nodes.KindObject|nodes.KindArray),
 	)
 }
 
+// typeCaseRight constructs a semantic type expression matched by typeCaseLeft.
+//
+// Built-in types, like "int" or "array", are stored as BuiltinType nodes, while class types, including
+// self, parent and static, are stored as name nodes. Nullable and union types are kept as separate nodes.
+func typeCaseRight(vr string) Op {
+	return Cases(vr+"_case",
+		Is(nil),
+		UASTType(phpuast.BuiltinType{}, Obj{
+			"Name": Var(vr),
+		}),
+		// by-ref wrapper is not a type by itself; it must not be matched on reverse
+		Check(Not(HasType(phpuast.ByRef{})), VarKind(vr, nodes.KindObject|nodes.KindArray)),
+	)
+}
+
 func convertBlock(typ, field string) Mapping {
 	if field == "" {
 		field = "stmts"
@@ -430,15 +1161,22 @@ func convertBlock(typ, field string) Mapping {
 	})
 }
 
-type splitUse struct {
-	vr string
+// splitStmts splits statements of a given type that declare multiple entities into separate statements,
+// one for each element of the list field.
+//
+// All statements produced from a single statement share the position of the original one, thus
+// adjacent statements of the same type and with the same non-empty position are joined back on reverse.
+type splitStmts struct {
+	vr   string
+	typ  string
+	list string
 }
 
-func (op splitUse) Kinds() nodes.Kind {
+func (op splitStmts) Kinds() nodes.Kind {
 	return nodes.KindArray
 }
 
-func (op splitUse) Check(st *State, n nodes.Node) (bool, error) {
+func (op splitStmts) Check(st *State, n nodes.Node) (bool, error) {
 	arr, ok := n.(nodes.Array)
 	if !ok {
 		return false, nil
@@ -449,9 +1187,9 @@ func (op splitUse) Check(st *State, n nodes.Node) (bool, error) {
 		if !ok && s != nil {
 			return false, nil
 		}
-		if uast.TypeOf(obj) == "Stmt_Use" {
-			uses, _ := obj["uses"].(nodes.Array)
-			if len(uses) > 1 {
+		if uast.TypeOf(obj) == op.typ {
+			list, _ := obj[op.list].(nodes.Array)
+			if len(list) > 1 {
 				contains = true
 				break
 			}
@@ -464,29 +1202,93 @@ func (op splitUse) Check(st *State, n nodes.Node) (bool, error) {
 	for i := 0; i < len(arr); i++ {
 		s := arr[i]
 		obj, ok := s.(nodes.Object)
-		if !ok || uast.TypeOf(obj) != "Stmt_Use" {
+		if !ok || uast.TypeOf(obj) != op.typ {
 			continue
 		}
-		uses, _ := obj["uses"].(nodes.Array)
-		if len(uses) < 2 {
+		list, _ := obj[op.list].(nodes.Array)
+		if len(list) < 2 {
 			continue
 		}
-		sub := make(nodes.Array, 0, len(uses))
-		for _, u := range uses {
-			use := obj.CloneObject()
-			use["uses"] = nodes.Array{u}
-			sub = append(sub, use)
+		sub := make(nodes.Array, 0, len(list))
+		for j, u := range list {
+			stmt := obj.CloneObject()
+			stmt[op.list] = nodes.Array{u}
+			if j != 0 {
+				// comments precede the whole statement, thus only the first one keeps them
+				delete(stmt, "comments")
+			}
+			sub = append(sub, stmt)
 		}
 		arr = append(arr[:i], append(sub, arr[i+1:]...)...)
-		i += len(uses) - 1
+		i += len(list) - 1
 	}
 	err := st.SetVar(op.vr, arr)
 	return err == nil, err
 }
 
-func (op splitUse) Construct(st *State, n nodes.Node) (nodes.Node, error) {
-	// TODO: add some info to join nodes back
-	return st.MustGetVar(op.vr)
+func (op splitStmts) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	v, err := st.MustGetVar(op.vr)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := v.(nodes.Array)
+	if !ok {
+		return v, nil
+	}
+	var out nodes.Array
+	for i, s := range arr {
+		if i == 0 || !op.isSplit(arr[i-1], s) {
+			if out != nil {
+				out = append(out, s)
+			}
+			continue
+		}
+		if out == nil {
+			out = make(nodes.Array, 0, len(arr))
+			out = append(out, arr[:i]...)
+		}
+		last := out[len(out)-1].(nodes.Object).CloneObject()
+		list, _ := last[op.list].(nodes.Array)
+		next, _ := s.(nodes.Object)[op.list].(nodes.Array)
+		last[op.list] = append(list.CloneList(), next...)
+		out[len(out)-1] = last
+	}
+	if out == nil {
+		return arr, nil
+	}
+	return out, nil
+}
+
+// isSplit checks if two adjacent statements were produced from a single statement by splitStmts.
+// Only the first statement of a split one has comments.
+func (op splitStmts) isSplit(prev, cur nodes.Node) bool {
+	p, ok := prev.(nodes.Object)
+	if !ok || uast.TypeOf(p) != op.typ {
+		return false
+	}
+	c, ok := cur.(nodes.Object)
+	if !ok || uast.TypeOf(c) != op.typ {
+		return false
+	}
+	if _, ok := c["comments"]; ok {
+		return false
+	}
+	size := len(p)
+	if _, ok := p["comments"]; ok {
+		size--
+	}
+	if p[uast.KeyPos] == nil || len(c) != size {
+		return false
+	}
+	for k, v := range p {
+		if k == op.list || k == "comments" {
+			continue
+		}
+		if cv, ok := c[k]; !ok || !nodes.Equal(v, cv) {
+			return false
+		}
+	}
+	return true
 }
 
 type opAdd struct {
@@ -535,3 +1337,454 @@ func (op opAdd) Construct(st *State, n nodes.Node) (nodes.Node, error) {
 	}
 	return n, nil
 }
+
+// flagNames lists modifier keywords in the order of PHP-Parser Stmt_Class::MODIFIER_* bits.
+var flagNames = []string{
+	"public",
+	"protected",
+	"private",
+	"static",
+	"abstract",
+	"final",
+}
+
+// opFlags converts a bitmask of modifiers to an array of keywords and back.
+type opFlags struct {
+	op Op
+}
+
+func (op opFlags) Kinds() nodes.Kind {
+	return nodes.KindArray
+}
+
+func (op opFlags) Check(st *State, n nodes.Node) (bool, error) {
+	arr, ok := n.(nodes.Array)
+	if !ok {
+		return false, nil
+	}
+	var flags nodes.Int
+	for _, v := range arr {
+		s, ok := v.(nodes.String)
+		if !ok {
+			return false, nil
+		}
+		found := false
+		for i, name := range flagNames {
+			if string(s) == name {
+				flags |= 1 << uint(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return op.op.Check(st, flags)
+}
+
+func (op opFlags) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	n, err := op.op.Construct(st, n)
+	if err != nil {
+		return nil, err
+	}
+	var flags int64
+	switch v := n.(type) {
+	case nodes.Int:
+		flags = int64(v)
+	case nodes.Uint:
+		flags = int64(v)
+	default:
+		return nil, ErrUnexpectedType.New(nodes.Int(0), n)
+	}
+	arr := nodes.Array{}
+	for i, name := range flagNames {
+		if flags&(1<<uint(i)) != 0 {
+			arr = append(arr, nodes.String(name))
+		}
+	}
+	return arr, nil
+}
+
+// memberFlag is an op that constructs the visibility or the static flag of a class member from a variable
+// with modifier flags.
+//
+// The value is derived from the flags, thus the flags must be checked first when the transformation is reversed.
+type memberFlag struct {
+	vr   string
+	part string
+}
+
+func (op memberFlag) Kinds() nodes.Kind {
+	return nodes.KindString | nodes.KindBool
+}
+
+func (op memberFlag) Check(st *State, n nodes.Node) (bool, error) {
+	v, err := op.Construct(st, nil)
+	if err != nil {
+		return false, err
+	}
+	return nodes.Equal(v, n), nil
+}
+
+func (op memberFlag) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	if n != nil {
+		return nil, ErrUnexpectedValue.New(n)
+	}
+	v, err := st.MustGetVar(op.vr)
+	if err != nil {
+		return nil, err
+	}
+	var flags int64
+	switch v := v.(type) {
+	case nodes.Int:
+		flags = int64(v)
+	case nodes.Uint:
+		flags = int64(v)
+	default:
+		return nil, ErrUnexpectedType.New(nodes.Int(0), v)
+	}
+	switch op.part {
+	case "visibility":
+		// the first three flags are visibility modifiers; members are public by default
+		for i, name := range flagNames[:3] {
+			if flags&(1<<uint(i)) != 0 {
+				return nodes.String(name), nil
+			}
+		}
+		return nodes.String(flagNames[0]), nil
+	case "static":
+		return nodes.Bool(flags&(1<<3) != 0), nil
+	}
+	return nil, ErrUnexpectedValue.New(nodes.String(op.part))
+}
+
+// arrayKind is an op that constructs the kind of an array literal from a variable with array items:
+// "list" if none of the items have a key and "map" otherwise.
+//
+// On reverse, the kind must agree with the items, which are expected to be checked before.
+type arrayKind struct {
+	vr string
+}
+
+func (op arrayKind) Kinds() nodes.Kind {
+	return nodes.KindString
+}
+
+func (op arrayKind) Check(st *State, n nodes.Node) (bool, error) {
+	v, err := op.Construct(st, nil)
+	if err != nil {
+		return false, err
+	}
+	return nodes.Equal(v, n), nil
+}
+
+func (op arrayKind) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	if n != nil {
+		return nil, ErrUnexpectedValue.New(n)
+	}
+	v, err := st.MustGetVar(op.vr)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := v.(nodes.Array)
+	if !ok && v != nil {
+		return nil, ErrUnexpectedType.New(nodes.Array{}, v)
+	}
+	for _, it := range items {
+		// skipped elements of destructuring targets are nil
+		if item, ok := it.(nodes.Object); ok && item["Key"] != nil {
+			return nodes.String("map"), nil
+		}
+	}
+	return nodes.String("list"), nil
+}
+
+// opLower checks both the original string and its lowercase form.
+// Reversal constructs the original string.
+type opLower struct {
+	orig  Op
+	lower Op
+}
+
+func (op opLower) Kinds() nodes.Kind {
+	return nodes.KindString
+}
+
+func (op opLower) Check(st *State, n nodes.Node) (bool, error) {
+	v, ok := n.(nodes.String)
+	if !ok {
+		return false, nil
+	}
+	if ok, err := op.lower.Check(st, nodes.String(strings.ToLower(string(v)))); err != nil || !ok {
+		return false, err
+	}
+	return op.orig.Check(st, v)
+}
+
+func (op opLower) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	return op.orig.Construct(st, n)
+}
+
+// opWithComments prepends an optional list of comments to an array.
+// Reversal splits leading comment nodes from the array.
+type opWithComments struct {
+	vr     string
+	exists string
+	op     Op
+}
+
+func (op opWithComments) Kinds() nodes.Kind {
+	return nodes.KindArray
+}
+
+func (op opWithComments) Check(st *State, n nodes.Node) (bool, error) {
+	arr, ok := n.(nodes.Array)
+	if !ok {
+		return false, nil
+	}
+	i := 0
+	for ; i < len(arr) && isComment(arr[i]); i++ {
+	}
+	if err := st.SetVar(op.exists, nodes.Bool(i != 0)); err != nil {
+		return false, err
+	}
+	if i != 0 {
+		if err := st.SetVar(op.vr, arr[:i]); err != nil {
+			return false, err
+		}
+	}
+	return op.op.Check(st, arr[i:])
+}
+
+func (op opWithComments) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	n, err := op.op.Construct(st, n)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := n.(nodes.Array)
+	if !ok {
+		return nil, ErrExpectedList.New(n)
+	}
+	ex, err := st.MustGetVar(op.exists)
+	if err != nil {
+		return nil, err
+	}
+	if exists, _ := ex.(nodes.Bool); !exists {
+		return arr, nil
+	}
+	cn, err := st.MustGetVar(op.vr)
+	if err != nil {
+		return nil, err
+	}
+	comments, ok := cn.(nodes.Array)
+	if !ok {
+		return nil, ErrExpectedList.New(cn)
+	}
+	out := make(nodes.Array, 0, len(comments)+len(arr))
+	out = append(out, comments...)
+	out = append(out, arr...)
+	return out, nil
+}
+
+// isComment checks if a node is a comment, either native or already converted to uast.Comment.
+func isComment(n nodes.Node) bool {
+	switch uast.TypeOf(n) {
+	case "Comment", "Comment_Doc", docComment, uast.TypeOf(uast.Comment{}):
+		return true
+	}
+	return false
+}
+
+// nameTypes lists all native name node types.
+var nameTypes = []nodes.Value{
+	nodes.String("Name"),
+	nodes.String("Name_FullyQualified"),
+	nodes.String("Name_Relative"),
+}
+
+// dedupNameComments removes comments from names if the same comments are attached to the parent node.
+//
+// PHP-Parser attaches comments to all nodes that start at the same token, thus a name that is the first
+// child of an expression will duplicate comments of this expression.
+var dedupNameComments = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	comments, ok := obj["comments"]
+	if !ok {
+		return obj, false, nil
+	}
+	isDup := func(n nodes.Node) bool {
+		sub, ok := n.(nodes.Object)
+		if !ok {
+			return false
+		}
+		typ, _ := sub[uast.KeyType].(nodes.String)
+		found := false
+		for _, t := range nameTypes {
+			if typ == t {
+				found = true
+				break
+			}
+		}
+		return found && nodes.Equal(sub["comments"], comments)
+	}
+	changed := false
+	for k, v := range obj {
+		if k == "comments" || !isDup(v) {
+			continue
+		}
+		if !changed {
+			obj = obj.CloneObject()
+			changed = true
+		}
+		name := v.(nodes.Object).CloneObject()
+		delete(name, "comments")
+		obj[k] = name
+	}
+	return obj, changed, nil
+})
+
+// docLabel joins the doc label with an optional indentation of the closing marker, which are stored in "label"
+// and "indent" variables.
+func docLabel() Op {
+	return opIndent{vr: "indent", exists: "indent_exists", op: Var("label")}
+}
+
+// opIndent prepends an optional indentation to a string value. The indentation variable is expected to be marked
+// with "<vr>_exists" optional variable.
+type opIndent struct {
+	vr     string
+	exists string
+	op     Op
+}
+
+func (op opIndent) Kinds() nodes.Kind {
+	return nodes.KindString
+}
+
+func (op opIndent) Check(st *State, n nodes.Node) (bool, error) {
+	s, ok := n.(nodes.String)
+	if !ok {
+		return false, nil
+	}
+	i := len(s) - len(strings.TrimLeft(string(s), " \t"))
+	if err := st.SetVar(op.exists, nodes.Bool(i != 0)); err != nil {
+		return false, err
+	}
+	if i != 0 {
+		if err := st.SetVar(op.vr, s[:i]); err != nil {
+			return false, err
+		}
+	}
+	return op.op.Check(st, s[i:])
+}
+
+func (op opIndent) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	n, err := op.op.Construct(st, n)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := n.(nodes.String)
+	if !ok {
+		return nil, ErrExpectedValue.New(n)
+	}
+	ex, err := st.MustGetVar(op.exists)
+	if err != nil {
+		return nil, err
+	}
+	if exists, _ := ex.(nodes.Bool); !exists {
+		return s, nil
+	}
+	in, err := st.MustGetVar(op.vr)
+	if err != nil {
+		return nil, err
+	}
+	indent, ok := in.(nodes.String)
+	if !ok {
+		return nil, ErrExpectedValue.New(in)
+	}
+	return indent + s, nil
+}
+
+// opPrefix adds a constant prefix to a string value.
+type opPrefix struct {
+	prefix string
+	op     Op
+}
+
+func (op opPrefix) Kinds() nodes.Kind {
+	return nodes.KindString
+}
+
+func (op opPrefix) Check(st *State, n nodes.Node) (bool, error) {
+	s, ok := n.(nodes.String)
+	if !ok || !strings.HasPrefix(string(s), op.prefix) {
+		return false, nil
+	}
+	return op.op.Check(st, s[len(op.prefix):])
+}
+
+func (op opPrefix) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	n, err := op.op.Construct(st, n)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := n.(nodes.String)
+	if !ok {
+		return nil, ErrExpectedValue.New(n)
+	}
+	return nodes.String(op.prefix) + s, nil
+}
+
+// opEachIn is like Each, but it reuses element states that were already stored in a variable by Each.
+// It allows to map a single native array to two parallel arrays, like names and kinds of imported symbols.
+// The op that populates the variable must be checked first.
+type opEachIn struct {
+	vr string
+	op Op
+}
+
+func (op opEachIn) Kinds() nodes.Kind {
+	return nodes.KindArray | nodes.KindNil
+}
+
+func (op opEachIn) Check(st *State, n nodes.Node) (bool, error) {
+	arr, ok := n.(nodes.Array)
+	if !ok && n != nil {
+		return false, nil
+	}
+	subs, ok := st.GetStateVar(op.vr)
+	if !ok {
+		return false, ErrVariableNotDefined.New(op.vr)
+	}
+	if len(arr) != len(subs) {
+		return false, nil
+	}
+	for i, sub := range arr {
+		if ok, err := op.op.Check(subs[i], sub); err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (op opEachIn) Construct(st *State, n nodes.Node) (nodes.Node, error) {
+	if n != nil {
+		return nil, ErrUnexpectedValue.New(n)
+	}
+	subs, ok := st.GetStateVar(op.vr)
+	if !ok {
+		return nil, ErrVariableNotDefined.New(op.vr)
+	}
+	if subs == nil {
+		return nil, nil
+	}
+	arr := make(nodes.Array, 0, len(subs))
+	for _, sub := range subs {
+		v, err := op.op.Construct(sub, nil)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
@@ -0,0 +1,920 @@
+package normalizer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bblfsh/sdk/v3/driver"
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+	"github.com/bblfsh/sdk/v3/uast/uastyaml"
+
+	"github.com/bblfsh/php-driver/driver/normalizer/phpuast"
+)
+
+const fixturesDir = "../../fixtures"
+
+// preprocess loads a native AST fixture and applies preprocessing transforms to it.
+func preprocess(t *testing.T, path string) nodes.Node {
+	code, err := ioutil.ReadFile(strings.TrimSuffix(path, ".native"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err := uastyaml.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err = Transforms.Do(context.Background(), driver.ModePreprocessed, string(code), ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ast
+}
+
+// reverseMappings returns a transformer that reverts a list of mappings.
+func reverseMappings(maps []Mapping) Transformer {
+	out := make(revMappings, 0, len(maps))
+	for i := len(maps) - 1; i >= 0; i-- {
+		out = append(out, Reverse(maps[i]))
+	}
+	return out
+}
+
+// revMappings applies reversed mappings to a tree.
+//
+// Mappings are applied to children before their parents, thus mappings of a parent may expect children that were
+// already normalized. Reversed mappings are applied in the opposite order: parents are reverted first.
+type revMappings []Mapping
+
+func (m revMappings) Do(n nodes.Node) (nodes.Node, error) {
+	for _, mp := range m {
+		src, dst := mp.Mapping()
+		st := NewState()
+		if ok, err := src.Check(st, n); err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		nn, err := dst.Construct(st, nil)
+		if err != nil {
+			return nil, err
+		}
+		n = nn
+	}
+	switch n := n.(type) {
+	case nodes.Object:
+		out := make(nodes.Object, len(n))
+		for k, v := range n {
+			v, err := m.Do(v)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = v
+		}
+		return out, nil
+	case nodes.Array:
+		out := make(nodes.Array, 0, len(n))
+		for _, v := range n {
+			v, err := m.Do(v)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}
+	return n, nil
+}
+
+func TestPreNormalizersRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(fixturesDir, "u2_import_*.php.native"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files,
+		filepath.Join(fixturesDir, "alias.php.native"),
+		filepath.Join(fixturesDir, "u2_class_field_multi.php.native"),
+	)
+	for _, path := range files {
+		path := path
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			exp := preprocess(t, path)
+
+			// transformations might modify the tree in place, thus we use a separate copy
+			ast, err := Mappings(PreNormilizers...).Do(preprocess(t, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// split statements must not duplicate comments
+			for _, typ := range []string{"Comment", "Comment_Doc"} {
+				if exp, got := countType(exp, typ), countType(ast, typ); exp != got {
+					t.Errorf("expected %d %s nodes, got %d", exp, typ, got)
+				}
+			}
+			ast, err = reverseMappings(PreNormilizers).Do(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !nodes.Equal(exp, ast) {
+				exp, _ := uastyaml.Marshal(exp)
+				got, _ := uastyaml.Marshal(ast)
+				t.Fatalf("tree differs after round trip\nexpected:\n%s\ngot:\n%s", exp, got)
+			}
+		})
+	}
+}
+
+// useStmts collects native use statements, indexed by the node offset.
+func useStmts(n nodes.Node) map[uint32]nodes.Object {
+	out := make(map[uint32]nodes.Object)
+	nodes.WalkPreOrder(n, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok {
+			return true
+		}
+		switch uast.TypeOf(obj) {
+		case "Stmt_Use", "Stmt_GroupUse":
+			out[uast.PositionsOf(obj).Start().Offset] = obj
+			return false
+		}
+		return true
+	})
+	return out
+}
+
+func TestImportsRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(fixturesDir, "u2_import_*.php.native"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, filepath.Join(fixturesDir, "alias.php.native"))
+	for _, path := range files {
+		path := path
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			exp := useStmts(preprocess(t, path))
+			if len(exp) == 0 {
+				// include statements are ambiguous on reverse, since only the "once" flag is kept
+				t.Skip("no use statements in the fixture")
+			}
+
+			ast, err := Mappings(PreNormilizers...).Do(preprocess(t, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err = Mappings(Normalizers...).Do(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := useStmts(ast); len(got) != 0 {
+				t.Fatalf("use statements were not normalized: %v", got)
+			}
+			ast, err = reverseMappings(Normalizers).Do(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err = reverseMappings(PreNormilizers).Do(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := useStmts(ast)
+			if len(got) != len(exp) {
+				t.Fatalf("expected %d statements, got %d", len(exp), len(got))
+			}
+			for off, stmt := range exp {
+				if !nodes.Equal(stmt, got[off]) {
+					exp, _ := uastyaml.Marshal(stmt)
+					got, _ := uastyaml.Marshal(got[off])
+					t.Errorf("statement at offset %d differs after round trip\nexpected:\n%s\ngot:\n%s", off, exp, got)
+				}
+			}
+		})
+	}
+}
+
+// byRefFlags collects byRef flags of native parameters and functions, indexed by the node offset.
+func byRefFlags(n nodes.Node) map[uint32]nodes.Value {
+	flags := make(map[uint32]nodes.Value)
+	nodes.WalkPreOrder(n, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok {
+			return true
+		}
+		switch uast.TypeOf(obj) {
+		case "Param", "Stmt_Function":
+			if v, ok := obj["byRef"].(nodes.Value); ok {
+				flags[uast.PositionsOf(obj).Start().Offset] = v
+			}
+		}
+		return true
+	})
+	return flags
+}
+
+func TestByRefRoundTrip(t *testing.T) {
+	path := filepath.Join(fixturesDir, "function_byRef.php.native")
+	exp := byRefFlags(preprocess(t, path))
+	if len(exp) == 0 {
+		t.Fatal("no by-ref flags in the fixture")
+	}
+
+	ast, err := Mappings(Normalizers...).Do(preprocess(t, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast, err = reverseMappings(Normalizers).Do(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := byRefFlags(ast)
+	if len(got) != len(exp) {
+		t.Fatalf("expected %d flags, got %d", len(exp), len(got))
+	}
+	for off, v := range exp {
+		if got[off] != v {
+			t.Errorf("byRef flag at offset %d: expected %v, got %v", off, v, got[off])
+		}
+	}
+}
+
+// semanticTypes collects types of semantic nodes that are left in a tree, except positions.
+func semanticTypes(n nodes.Node) map[string]int {
+	out := make(map[string]int)
+	nodes.WalkPreOrder(n, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok {
+			return true
+		}
+		switch typ := uast.TypeOf(obj); typ {
+		case uast.TypeOf(uast.Positions{}):
+			return false
+		default:
+			if strings.HasPrefix(typ, "uast:") || strings.HasPrefix(typ, "phpuast:") {
+				out[typ]++
+			}
+		}
+		return true
+	})
+	return out
+}
+
+func TestQualifyNamespacesRoundTrip(t *testing.T) {
+	for _, name := range []string{
+		"u2_namespace_braced_decls.php", "u2_namespace_unbraced_decls.php",
+		"function_byRef.php", // global namespace
+	} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(fixturesDir, name+".native")
+			sem, err := Mappings(PreNormilizers...).Do(preprocess(t, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sem, err = Mappings(Normalizers...).Do(sem)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err := qualifyNamespaces.Func().Do(sem.Clone())
+			if err != nil {
+				t.Fatal(err)
+			}
+			typ := uast.TypeOf(phpuast.Declaration{})
+			if semanticTypes(ast)[typ] == 0 {
+				t.Fatal("no declarations were qualified")
+			}
+			nodes.WalkPreOrder(ast, func(n nodes.Node) bool {
+				if obj, ok := n.(nodes.Object); ok && uast.TypeOf(obj) == typ {
+					decl, _ := obj["Node"].(nodes.Object)
+					if !nodes.Equal(obj[uast.KeyPos], decl[uast.KeyPos]) {
+						t.Errorf("declaration of %v doesn't have the position of the declared node", obj[keyFullName])
+					}
+				}
+				return true
+			})
+			ast, err = unqualifyNamespaces.Func().Do(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !nodes.Equal(sem, ast) {
+				exp, _ := uastyaml.Marshal(sem)
+				got, _ := uastyaml.Marshal(ast)
+				t.Fatalf("tree differs after round trip\nexpected:\n%s\ngot:\n%s", exp, got)
+			}
+			ast, err = reverseMappings(Normalizers).Do(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast, err = reverseMappings(PreNormilizers).Do(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if left := semanticTypes(ast); len(left) != 0 {
+				t.Fatalf("semantic nodes left after reverse: %v", left)
+			}
+		})
+	}
+}
+
+// normalize applies all normalization steps to a preprocessed tree.
+func normalize(t *testing.T, ast nodes.Node) nodes.Node {
+	for _, tr := range Normalize {
+		var err error
+		ast, err = tr.Do(ast)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ast
+}
+
+func TestNormalizeRoundTrip(t *testing.T) {
+	for _, name := range []string{
+		"u2_name_resolution.php",
+		"u2_namespace_braced_decls.php",
+		"u2_include_paths.php",
+		"u2_phpdoc.php",
+	} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(fixturesDir, name+".native")
+			sem := normalize(t, preprocess(t, path))
+
+			ast := sem.Clone()
+			for _, tr := range []Transformer{
+				unresolveNames.Func(),
+				unqualifyNamespaces.Func(),
+				joinDocs,
+				reverseMappings(Normalizers),
+				reverseMappings(PreNormilizers),
+			} {
+				var err error
+				ast, err = tr.Do(ast)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if left := semanticTypes(ast); len(left) != 0 {
+				t.Fatalf("semantic nodes left after reverse: %v", left)
+			}
+			// native names are ambiguous on reverse, thus the trees are compared after normalizing them again
+			if got := normalize(t, ast); !nodes.Equal(sem, got) {
+				exp, _ := uastyaml.Marshal(sem)
+				got, _ := uastyaml.Marshal(got)
+				t.Fatalf("tree differs after round trip\nexpected:\n%s\ngot:\n%s", exp, got)
+			}
+		})
+	}
+}
+
+func TestDerivedFields(t *testing.T) {
+	st := NewState()
+	// protected static
+	if err := st.SetVar("flags", nodes.Int(1<<1|1<<3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetVar("items", nodes.Array{nil, nodes.Object{"Key": nodes.String("a")}}); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		op  Op
+		val nodes.Node
+		exp bool
+	}{
+		{op: memberFlag{vr: "flags", part: "visibility"}, val: nodes.String("protected"), exp: true},
+		{op: memberFlag{vr: "flags", part: "visibility"}, val: nodes.String("public"), exp: false},
+		{op: memberFlag{vr: "flags", part: "static"}, val: nodes.Bool(true), exp: true},
+		{op: memberFlag{vr: "flags", part: "static"}, val: nodes.Bool(false), exp: false},
+		{op: arrayKind{vr: "items"}, val: nodes.String("map"), exp: true},
+		{op: arrayKind{vr: "items"}, val: nodes.String("list"), exp: false},
+	}
+	for _, c := range cases {
+		ok, err := c.op.Check(st, c.val)
+		if err != nil {
+			t.Fatal(err)
+		} else if ok != c.exp {
+			t.Errorf("%#v: expected %v for %v, got %v", c.op, c.exp, c.val, ok)
+		}
+	}
+}
+
+// pos returns positions of a node with given start and end offsets.
+func pos(start, end uint32) nodes.Object {
+	return uast.Positions{
+		uast.KeyStart: {Offset: start},
+		uast.KeyEnd:   {Offset: end},
+	}.ToObject()
+}
+
+// comment returns a preprocessed native comment at a given offset.
+func comment(off uint32, text string) nodes.Object {
+	return nodes.Object{
+		uast.KeyType: nodes.String("Comment"),
+		uast.KeyPos:  uast.Positions{uast.KeyStart: {Offset: off}}.ToObject(),
+		"text":       nodes.String(text),
+	}
+}
+
+// nativeName returns a preprocessed native name that starts at a given offset.
+func nativeName(start uint32, name string) nodes.Object {
+	return nodes.Object{
+		uast.KeyType: nodes.String("Name"),
+		uast.KeyPos:  pos(start, start+uint32(len(name))),
+		"parts":      nodes.Array{nodes.String(name)},
+	}
+}
+
+// countType counts nodes of a given type in a tree.
+func countType(n nodes.Node, typ string) int {
+	cnt := 0
+	nodes.WalkPreOrder(n, func(n nodes.Node) bool {
+		if obj, ok := n.(nodes.Object); ok && uast.TypeOf(obj) == typ {
+			cnt++
+		}
+		return true
+	})
+	return cnt
+}
+
+// roundTrip normalizes a native node and checks that it was mapped to a semantic node of a given type
+// without losing comments, and that the reversal restores the native node. It returns the semantic node.
+func roundTrip(t *testing.T, native nodes.Node, typ string) nodes.Node {
+	t.Helper()
+	// transformations might modify the tree in place, thus we use a separate copy
+	ast, err := Mappings(Normalizers...).Do(native.Clone())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := uast.TypeOf(ast); got != typ {
+		got, _ := uastyaml.Marshal(ast)
+		t.Fatalf("expected %s node, got:\n%s", typ, got)
+	}
+	exp := countType(native, "Comment") + countType(native, "Comment_Doc")
+	if got := countType(ast, uast.TypeOf(uast.Comment{})); got != exp {
+		t.Fatalf("expected %d comments, got %d", exp, got)
+	}
+	back, err := reverseMappings(Normalizers).Do(ast.Clone())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nodes.Equal(native, back) {
+		exp, _ := uastyaml.Marshal(native)
+		got, _ := uastyaml.Marshal(back)
+		t.Fatalf("tree differs after round trip\nexpected:\n%s\ngot:\n%s", exp, got)
+	}
+	return ast
+}
+
+func TestCommentedNodes(t *testing.T) {
+	cases := []struct {
+		name   string
+		native nodes.Object
+		typ    string
+	}{
+		{
+			// /* c */ A::m insteadof B;
+			name: "trait precedence",
+			native: nodes.Object{
+				uast.KeyType: nodes.String("Stmt_TraitUseAdaptation_Precedence"),
+				uast.KeyPos:  pos(8, 25),
+				"trait":      nativeName(8, "A"),
+				"method":     nodes.String("m"),
+				"insteadof":  nodes.Array{nativeName(23, "B")},
+				"comments":   nodes.Array{comment(0, "/* c */")},
+			},
+			typ: uast.TypeOf(phpuast.TraitPrecedence{}),
+		},
+		{
+			// /* c */ m as protected n;
+			name: "trait alias",
+			native: nodes.Object{
+				uast.KeyType:  nodes.String("Stmt_TraitUseAdaptation_Alias"),
+				uast.KeyPos:   pos(8, 25),
+				"trait":       nil,
+				"method":      nodes.String("m"),
+				"newName":     nodes.String("n"),
+				"newModifier": nodes.Int(2),
+				"comments":    nodes.Array{comment(0, "/* c */")},
+			},
+			typ: uast.TypeOf(phpuast.TraitAlias{}),
+		},
+		{
+			// /** @var int */ public /* c */ $a;
+			name: "property",
+			native: nodes.Object{
+				uast.KeyType: nodes.String("Stmt_Property"),
+				uast.KeyPos:  pos(16, 34),
+				"flags":      nodes.Int(1),
+				"type":       nodes.Int(1),
+				"props": nodes.Array{nodes.Object{
+					uast.KeyType: nodes.String("Stmt_PropertyProperty"),
+					uast.KeyPos:  pos(31, 33),
+					"name":       nativeName(32, "a"),
+					"default":    nil,
+					"comments":   nodes.Array{comment(23, "/* c */")},
+				}},
+				"comments": nodes.Array{nodes.Object{
+					uast.KeyType: nodes.String("Comment_Doc"),
+					uast.KeyPos:  uast.Positions{uast.KeyStart: {Offset: 0}}.ToObject(),
+					"text":       nodes.String("/** @var int */"),
+				}},
+			},
+			typ: uast.TypeOf(phpuast.Field{}),
+		},
+		{
+			// const /* c */ A = 1;
+			name: "class constant",
+			native: nodes.Object{
+				uast.KeyType: nodes.String("Stmt_ClassConst"),
+				uast.KeyPos:  pos(0, 20),
+				"flags":      nodes.Int(0),
+				"consts": nodes.Array{nodes.Object{
+					uast.KeyType: nodes.String("Const"),
+					uast.KeyPos:  pos(14, 19),
+					"name":       nativeName(14, "A"),
+					"value": nodes.Object{
+						uast.KeyType: nodes.String("Scalar_LNumber"),
+						uast.KeyPos:  pos(18, 19),
+						"attributes": nodes.Object{"kind": nodes.Int(10)},
+						"value":      nodes.Int(1),
+					},
+					"comments": nodes.Array{comment(6, "/* c */")},
+				}},
+			},
+			typ: uast.TypeOf(phpuast.Field{}),
+		},
+		{
+			// function f(): /* c */ ?int {}
+			name: "nullable type",
+			native: nodes.Object{
+				uast.KeyType: nodes.String("NullableType"),
+				uast.KeyPos:  pos(22, 26),
+				"type":       nodes.String("int"),
+				"comments":   nodes.Array{comment(14, "/* c */")},
+			},
+			typ: uast.TypeOf(phpuast.NullableType{}),
+		},
+		{
+			// function f(): /* c */ int|A {}
+			name: "union type",
+			native: nodes.Object{
+				uast.KeyType: nodes.String("UnionType"),
+				uast.KeyPos:  pos(22, 27),
+				"types":      nodes.Array{nodes.String("int"), nativeName(26, "A")},
+				"comments":   nodes.Array{comment(14, "/* c */")},
+			},
+			typ: uast.TypeOf(phpuast.UnionType{}),
+		},
+		{
+			// static /* c */ $x;
+			name: "static variable",
+			native: nodes.Object{
+				uast.KeyType: nodes.String("Stmt_StaticVar"),
+				uast.KeyPos:  pos(15, 17),
+				"name":       nativeName(16, "x"),
+				"default":    nil,
+				"comments":   nodes.Array{comment(7, "/* c */")},
+			},
+			typ: uast.TypeOf(phpuast.StaticVar{}),
+		},
+		{
+			// function () use (/* c */ &$x) {}
+			name: "closure use",
+			native: nodes.Object{
+				uast.KeyType: nodes.String("Expr_ClosureUse"),
+				uast.KeyPos:  pos(25, 28),
+				"byRef":      nodes.Bool(true),
+				"var":        nodes.String("x"),
+				"comments":   nodes.Array{comment(17, "/* c */")},
+			},
+			typ: uast.TypeOf(phpuast.Capture{}),
+		},
+		{
+			// /* c */ include_once 'x.php';
+			name: "include",
+			native: nodes.Object{
+				uast.KeyType: nodes.String("Expr_Include"),
+				uast.KeyPos:  pos(8, 29),
+				"expr": nodes.Object{
+					uast.KeyType: nodes.String("Scalar_String"),
+					uast.KeyPos:  pos(21, 28),
+					"attributes": nodes.Object{"kind": nodes.Int(1)},
+					"value":      nodes.String("x.php"),
+				},
+				"type":     nodes.Int(2),
+				"comments": nodes.Array{comment(0, "/* c */")},
+			},
+			typ: uast.TypeOf(uast.RuntimeImport{}),
+		},
+		{
+			// try {} /* c */ finally {}
+			name: "finally",
+			native: nodes.Object{
+				uast.KeyType: nodes.String("Stmt_TryCatch"),
+				uast.KeyPos:  pos(0, 25),
+				"stmts":      nodes.Array{},
+				"catches":    nodes.Array{},
+				"finally": nodes.Object{
+					uast.KeyType: nodes.String("Stmt_Finally"),
+					uast.KeyPos:  pos(15, 25),
+					"stmts":      nodes.Array{},
+					"comments":   nodes.Array{comment(7, "/* c */")},
+				},
+			},
+			typ: uast.TypeOf(phpuast.Try{}),
+		},
+		{
+			// new /* c */ Foo;
+			name: "name",
+			native: nodes.Object{
+				uast.KeyType: nodes.String("Name"),
+				uast.KeyPos:  pos(12, 15),
+				"parts":      nodes.Array{nodes.String("Foo")},
+				"comments":   nodes.Array{comment(4, "/* c */")},
+			},
+			typ: uast.TypeOf(uast.Identifier{}),
+		},
+		{
+			// new /* c */ A\B;
+			name: "qualified name",
+			native: nodes.Object{
+				uast.KeyType: nodes.String("Name"),
+				uast.KeyPos:  pos(12, 15),
+				"parts":      nodes.Array{nodes.String("A"), nodes.String("B")},
+				"comments":   nodes.Array{comment(4, "/* c */")},
+			},
+			typ: uast.TypeOf(uast.QualifiedIdentifier{}),
+		},
+		{
+			// new /* c */ \A\B;
+			name: "anchored name",
+			native: nodes.Object{
+				uast.KeyType: nodes.String("Name_FullyQualified"),
+				uast.KeyPos:  pos(12, 16),
+				"parts":      nodes.Array{nodes.String("A"), nodes.String("B")},
+				"comments":   nodes.Array{comment(4, "/* c */")},
+			},
+			typ: uast.TypeOf(phpuast.AnchoredName{}),
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			roundTrip(t, c.native, c.typ)
+		})
+	}
+}
+
+func TestUnionType(t *testing.T) {
+	// the current version of the parser doesn't support union types, thus the node is built by hand
+	// for a "int|A" type
+	native := nodes.Object{
+		uast.KeyType: nodes.String("UnionType"),
+		uast.KeyPos:  pos(10, 15),
+		"types":      nodes.Array{nodes.String("int"), nativeName(14, "A")},
+	}
+	exp := nodes.Object{
+		uast.KeyType: nodes.String(uast.TypeOf(phpuast.UnionType{})),
+		uast.KeyPos:  pos(10, 15),
+		"Types": nodes.Array{
+			nodes.Object{
+				uast.KeyType: nodes.String(uast.TypeOf(phpuast.BuiltinType{})),
+				"Name":       nodes.String("int"),
+			},
+			nodes.Object{
+				uast.KeyType: nodes.String(uast.TypeOf(uast.Identifier{})),
+				uast.KeyPos:  pos(14, 15),
+				"Name":       nodes.String("A"),
+			},
+		},
+	}
+	if ast := roundTrip(t, native, uast.TypeOf(phpuast.UnionType{})); !nodes.Equal(exp, ast) {
+		exp, _ := uastyaml.Marshal(exp)
+		got, _ := uastyaml.Marshal(ast)
+		t.Fatalf("unexpected semantic node\nexpected:\n%s\ngot:\n%s", exp, got)
+	}
+}
+
+// inlineHTMLStmt creates an inline HTML statement for a range of the code.
+func inlineHTMLStmt(code string, start, end int) nodes.Object {
+	return nodes.Object{
+		uast.KeyType: nodes.String("Stmt_InlineHTML"),
+		uast.KeyPos: uast.Positions{
+			uast.KeyStart: {Offset: uint32(start)},
+			uast.KeyEnd:   {Offset: uint32(end)},
+		}.ToObject(),
+		"value": nodes.String(code[start:end]),
+	}
+}
+
+// htmlNodes parses inline HTML statements and lists types of HTML nodes with the code they span.
+func htmlNodes(t *testing.T, code string, ast nodes.Node) []string {
+	ast, err := inlineHTML{enabled: true}.OnCode(code).Do(ast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	nodes.WalkPreOrder(ast, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok || uast.TypeOf(obj) == "Stmt_InlineHTML" {
+			return true
+		}
+		if pos := uast.PositionsOf(obj); pos.Start() != nil {
+			out = append(out, uast.TypeOf(obj)+" "+code[pos.Start().Offset:pos.End().Offset])
+		}
+		return true
+	})
+	return out
+}
+
+func TestInlineHTML(t *testing.T) {
+	const code = `<!DOCTYPE html>
+<form action="<?php echo $url ?>" method="post">
+<!-- query -->
+<input type="text" name="q" disabled>
+<script>if (a < b) { go(); }</script>
+<p class='x'>Hi <b>there</p></form>
+`
+	const php = `<?php echo $url ?>`
+	i := strings.Index(code, php)
+	got := htmlNodes(t, code, nodes.Array{
+		inlineHTMLStmt(code, 0, i),
+		inlineHTMLStmt(code, i+len(php), len(code)),
+	})
+	exp := []string{
+		"HTMLDoctype <!DOCTYPE html>",
+		"HTMLElement <form action=\"",
+		"HTMLAttribute action=\"",
+		"HTMLText \" method=\"post\">\n",
+		"HTMLComment <!-- query -->",
+		"HTMLElement <input type=\"text\" name=\"q\" disabled>",
+		"HTMLAttribute type=\"text\"",
+		"HTMLAttribute name=\"q\"",
+		"HTMLAttribute disabled",
+		"HTMLElement <script>if (a < b) { go(); }</script>",
+		"HTMLText if (a < b) { go(); }",
+		"HTMLElement <p class='x'>Hi <b>there</p>",
+		"HTMLAttribute class='x'",
+		"HTMLText Hi ",
+		"HTMLElement <b>there",
+		"HTMLText there",
+		"HTMLEndTag </form>",
+	}
+	if strings.Join(got, "\n") != strings.Join(exp, "\n") {
+		t.Fatalf("unexpected HTML nodes\nexpected:\n%s\n\ngot:\n%s", strings.Join(exp, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestInlineHTMLNonASCII(t *testing.T) {
+	// both letters change their length in UTF-8 when lowercased
+	const code = "<TITLE>ȺȺȺȺ İ</Title><p>ȺİȺ</p>"
+	got := htmlNodes(t, code, inlineHTMLStmt(code, 0, len(code)))
+	exp := []string{
+		"HTMLElement <TITLE>ȺȺȺȺ İ</Title>",
+		"HTMLText ȺȺȺȺ İ",
+		"HTMLElement <p>ȺİȺ</p>",
+		"HTMLText ȺİȺ",
+	}
+	if strings.Join(got, "\n") != strings.Join(exp, "\n") {
+		t.Fatalf("unexpected HTML nodes\nexpected:\n%s\n\ngot:\n%s", strings.Join(exp, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+// listNodes collects types of native lists, arrays and their items, as well as the number of items,
+// indexed by the node offset.
+func listNodes(n nodes.Node) map[uint32]string {
+	out := make(map[uint32]string)
+	nodes.WalkPreOrder(n, func(n nodes.Node) bool {
+		obj, ok := n.(nodes.Object)
+		if !ok {
+			return true
+		}
+		switch typ := uast.TypeOf(obj); typ {
+		case "Expr_List", "Expr_Array", "Expr_ArrayItem":
+			items, _ := obj["items"].(nodes.Array)
+			out[uast.PositionsOf(obj).Start().Offset] = fmt.Sprintf("%s(%d)", typ, len(items))
+		}
+		return true
+	})
+	return out
+}
+
+func TestDestructuringRoundTrip(t *testing.T) {
+	for _, name := range []string{"array_destructuring.php", "list_withkeys.php", "foreach.php"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(fixturesDir, name+".native")
+			exp := listNodes(preprocess(t, path))
+
+			ast, err := Mappings(Normalizers...).Do(preprocess(t, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := listNodes(ast); len(got) != 0 {
+				t.Fatalf("lists were not normalized: %v", got)
+			}
+			ast, err = reverseMappings(Normalizers).Do(ast)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := listNodes(ast)
+			if len(got) != len(exp) {
+				t.Fatalf("expected %d nodes, got %d", len(exp), len(got))
+			}
+			for off, typ := range exp {
+				if got[off] != typ {
+					t.Errorf("node at offset %d: expected %s, got %s", off, typ, got[off])
+				}
+			}
+		})
+	}
+}
+
+func TestCutDocWord(t *testing.T) {
+	cases := []struct {
+		in, word, rest string
+	}{
+		{in: "int $a text", word: "int", rest: "$a text"},
+		{in: "  array<int, string> $a", word: "array<int, string>", rest: "$a"},
+		{in: "array{a: int, b: string}|null $a", word: "array{a: int, b: string}|null", rest: "$a"},
+		{in: "(A | B)[] ...$a", word: "(A | B)[]", rest: "...$a"},
+		{in: "int", word: "int", rest: ""},
+		{in: "", word: "", rest: ""},
+	}
+	for _, c := range cases {
+		word, rest := cutDocWord(c.in)
+		if word != c.word || rest != c.rest {
+			t.Errorf("cutDocWord(%q): expected (%q, %q), got (%q, %q)", c.in, c.word, c.rest, word, rest)
+		}
+	}
+}
+
+func TestSplitDocType(t *testing.T) {
+	cases := []struct {
+		in  string
+		out []string
+	}{
+		{in: "int", out: []string{"int"}},
+		{in: "int|null", out: []string{"int", "null"}},
+		{in: "array<int|string, A|B>|null", out: []string{"array<int|string, A|B>", "null"}},
+		{in: "(A|B)[]|C", out: []string{"(A|B)[]", "C"}},
+	}
+	for _, c := range cases {
+		if out := splitDocType(c.in); !reflect.DeepEqual(out, c.out) {
+			t.Errorf("splitDocType(%q): expected %q, got %q", c.in, c.out, out)
+		}
+	}
+}
+
+func TestParseDoc(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		exp  docBlock
+	}{
+		{
+			name: "summary",
+			text: "Summary.\nDescription\non two lines.",
+			exp:  docBlock{Summary: "Summary.", Description: "Description\non two lines."},
+		},
+		{
+			name: "no dot",
+			text: "\nSummary without a dot\ncontinues here\n\nDescription.",
+			exp:  docBlock{Summary: "Summary without a dot\ncontinues here", Description: "Description."},
+		},
+		{
+			name: "params",
+			text: "@param array<int, string> $a first\n@param &$b second\n@param int ...$c the rest\n  of values",
+			exp: docBlock{Tags: []docTag{
+				{Name: "param", Types: []string{"array<int, string>"}, Var: "a", Text: "first"},
+				{Name: "param", Var: "b", Text: "second"},
+				{Name: "param", Types: []string{"int"}, Var: "c", Text: "the rest\nof values"},
+			}},
+		},
+		{
+			name: "other tags",
+			text: "Summary.\n@return int|null\n@throws \\RuntimeException if failed\n@deprecated",
+			exp: docBlock{Summary: "Summary.", Tags: []docTag{
+				{Name: "return", Types: []string{"int", "null"}},
+				{Name: "throws", Types: []string{`\RuntimeException`}, Text: "if failed"},
+				{Name: "deprecated"},
+			}},
+		},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if doc := parseDoc(c.text); !reflect.DeepEqual(doc, c.exp) {
+				t.Fatalf("unexpected doc\nexpected: %#v\ngot: %#v", c.exp, doc)
+			}
+		})
+	}
+}
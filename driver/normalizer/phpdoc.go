@@ -0,0 +1,352 @@
+package normalizer
+
+import (
+	"strings"
+
+	"github.com/bblfsh/sdk/v3/uast"
+	"github.com/bblfsh/sdk/v3/uast/nodes"
+	. "github.com/bblfsh/sdk/v3/uast/transformer"
+
+	"github.com/bblfsh/php-driver/driver/normalizer/phpuast"
+)
+
+// docBlock is a parsed PHPDoc comment.
+type docBlock struct {
+	Summary     string
+	Description string
+	Tags        []docTag
+}
+
+// docTag is a single PHPDoc tag, like "@param int $a Description".
+type docTag struct {
+	Name  string
+	Types []string
+	Var   string
+	Text  string
+}
+
+// docTagsWithVar lists tags that may document a variable after the type.
+var docTagsWithVar = map[string]bool{
+	"param":          true,
+	"param-read":     true,
+	"param-write":    true,
+	"property":       true,
+	"property-read":  true,
+	"property-write": true,
+	"var":            true,
+}
+
+// docTagsWithType lists tags that start with a type expression.
+var docTagsWithType = map[string]bool{
+	"param":          true,
+	"param-read":     true,
+	"param-write":    true,
+	"property":       true,
+	"property-read":  true,
+	"property-write": true,
+	"var":            true,
+	"return":         true,
+	"throws":         true,
+}
+
+// parseDoc parses the text of a PHPDoc comment with comment tokens and leading asterisks already removed.
+func parseDoc(text string) docBlock {
+	var (
+		doc   docBlock
+		head  []string
+		tag   *docTag
+		lines []string
+	)
+	flush := func() {
+		if tag == nil {
+			return
+		}
+		tag.Text = strings.TrimSpace(strings.Join(lines, "\n"))
+		doc.Tags = append(doc.Tags, *tag)
+		tag, lines = nil, nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "@") {
+			flush()
+			tag, lines = parseDocTag(line[1:])
+			continue
+		}
+		if tag != nil {
+			lines = append(lines, line)
+		} else {
+			head = append(head, line)
+		}
+	}
+	flush()
+
+	// the summary ends either at the first empty line or at the line ending with a dot
+	i := 0
+	for ; i < len(head); i++ {
+		if head[i] == "" {
+			if i == 0 {
+				continue
+			}
+			break
+		}
+		if strings.HasSuffix(head[i], ".") {
+			i++
+			break
+		}
+	}
+	doc.Summary = strings.TrimSpace(strings.Join(head[:i], "\n"))
+	doc.Description = strings.TrimSpace(strings.Join(head[i:], "\n"))
+	return doc
+}
+
+// parseDocTag parses the first line of a tag, not including the '@' sign.
+// It returns the tag and the remaining text on the line.
+func parseDocTag(line string) (*docTag, []string) {
+	name, rest := cutDocWord(line)
+	tag := &docTag{Name: name}
+	if docTagsWithType[name] && rest != "" && !strings.HasPrefix(rest, "$") &&
+		!strings.HasPrefix(rest, "&$") && !strings.HasPrefix(rest, "...$") {
+		var typ string
+		typ, rest = cutDocWord(rest)
+		tag.Types = splitDocType(typ)
+	}
+	if docTagsWithVar[name] {
+		w, r := cutDocWord(rest)
+		v := strings.TrimLeft(w, "&.")
+		if strings.HasPrefix(v, "$") {
+			tag.Var = v[1:]
+			rest = r
+		}
+	}
+	return tag, []string{rest}
+}
+
+// cutDocWord returns the first word of a tag line and the rest of the line.
+// Spaces inside generic types and array shapes, like "array<int, string>", are not considered separators.
+func cutDocWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '<', '(', '{', '[':
+			depth++
+		case '>', ')', '}', ']':
+			depth--
+		case ' ', '\t':
+			if depth <= 0 {
+				return s[:i], strings.TrimSpace(s[i:])
+			}
+		}
+	}
+	return s, ""
+}
+
+// splitDocType splits a union type expression like "int|null" into separate types.
+func splitDocType(s string) []string {
+	var (
+		out   []string
+		depth = 0
+		last  = 0
+	)
+	for i, r := range s {
+		switch r {
+		case '<', '(', '{', '[':
+			depth++
+		case '>', ')', '}', ']':
+			depth--
+		case '|':
+			if depth == 0 {
+				out = append(out, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(out, s[last:])
+}
+
+// toNode converts a parsed doc tag to a DocTag node.
+func (t docTag) toNode() nodes.Object {
+	obj := nodes.Object{
+		uast.KeyType: nodes.String(uast.TypeOf(phpuast.DocTag{})),
+		"Name":       nodes.String(t.Name),
+		"Types":      nil,
+		"Var":        nodes.String(t.Var),
+		"Text":       nodes.String(t.Text),
+		"Argument":   nil,
+	}
+	if len(t.Types) != 0 {
+		arr := make(nodes.Array, 0, len(t.Types))
+		for _, typ := range t.Types {
+			arr = append(arr, nodes.String(typ))
+		}
+		obj["Types"] = arr
+	}
+	return obj
+}
+
+// toNode converts a parsed doc comment to a Doc node with given positions.
+func (d docBlock) toNode(pos nodes.Node) nodes.Object {
+	tags := make(nodes.Array, 0, len(d.Tags))
+	for _, t := range d.Tags {
+		tags = append(tags, t.toNode())
+	}
+	obj := nodes.Object{
+		uast.KeyType:  nodes.String(uast.TypeOf(phpuast.Doc{})),
+		"Summary":     nodes.String(d.Summary),
+		"Description": nodes.String(d.Description),
+		"Tags":        tags,
+	}
+	if pos != nil {
+		obj[uast.KeyPos] = pos
+	}
+	return obj
+}
+
+// docComment is a type of intermediate nodes created by the normalizer for doc comments. The node stores
+// the uast.Comment in the Comment field.
+//
+// Doc comments cannot be distinguished from block comments once converted to uast.Comment, thus they are
+// kept in a separate node until all mappings are applied.
+const docComment = "DocComment"
+
+// splitDocs replaces doc comments in lists of nodes with a uast.Comment followed by the Doc node
+// with the parsed text of the comment.
+var splitDocs = TransformFunc(func(n nodes.Node) (nodes.Node, bool, error) {
+	arr, ok := n.(nodes.Array)
+	if !ok {
+		return n, false, nil
+	}
+	var out nodes.Array
+	for i, v := range arr {
+		obj, ok := v.(nodes.Object)
+		if !ok || uast.TypeOf(obj) != docComment {
+			if out != nil {
+				out = append(out, v)
+			}
+			continue
+		}
+		if out == nil {
+			out = make(nodes.Array, 0, len(arr)+1)
+			out = append(out, arr[:i]...)
+		}
+		com, _ := obj["Comment"].(nodes.Object)
+		text, _ := com["Text"].(nodes.String)
+		out = append(out, com, parseDoc(string(text)).toNode(com[uast.KeyPos]))
+	}
+	if out == nil {
+		return n, false, nil
+	}
+	return out, true, nil
+})
+
+// joinDocs reverts splitDocs by removing Doc nodes and marking comments that precede them as doc comments.
+var joinDocs = TransformFunc(func(n nodes.Node) (nodes.Node, bool, error) {
+	arr, ok := n.(nodes.Array)
+	if !ok {
+		return n, false, nil
+	}
+	var out nodes.Array
+	for i, v := range arr {
+		if uast.TypeOf(v) != uast.TypeOf(phpuast.Doc{}) || i == 0 ||
+			uast.TypeOf(arr[i-1]) != uast.TypeOf(uast.Comment{}) {
+			if out != nil {
+				out = append(out, v)
+			}
+			continue
+		}
+		if out == nil {
+			out = make(nodes.Array, 0, len(arr))
+			out = append(out, arr[:i]...)
+		}
+		out[len(out)-1] = nodes.Object{
+			uast.KeyType: nodes.String(docComment),
+			"Comment":    arr[i-1],
+		}
+	}
+	if out == nil {
+		return n, false, nil
+	}
+	return out, true, nil
+})
+
+// linkDocParams links @param tags of a function documentation to function arguments.
+//
+// A copy of the name of the matching argument is stored in the Argument field of the tag. The copy has the position
+// of the argument node.
+var linkDocParams = TransformObjFunc(func(obj nodes.Object) (nodes.Object, bool, error) {
+	if uast.TypeOf(obj) != uast.TypeOf(uast.FunctionGroup{}) {
+		return obj, false, nil
+	}
+	group, _ := obj["Nodes"].(nodes.Array)
+	var (
+		docs []int
+		args nodes.Array
+	)
+	for i, n := range group {
+		switch uast.TypeOf(n) {
+		case uast.TypeOf(phpuast.Doc{}):
+			docs = append(docs, i)
+		case uast.TypeOf(uast.Alias{}):
+			args = funcArgs(n.(nodes.Object)["Node"])
+		case uast.TypeOf(uast.Function{}):
+			args = funcArgs(n)
+		}
+	}
+	if len(docs) == 0 || len(args) == 0 {
+		return obj, false, nil
+	}
+	// names of arguments have no positions, thus the position of the argument is used for the reference
+	argNames := make(map[string]nodes.Object, len(args))
+	for _, a := range args {
+		arg, _ := a.(nodes.Object)
+		name, _ := arg["Name"].(nodes.Object)
+		if s, ok := name["Name"].(nodes.String); ok {
+			ref := name.CloneObject()
+			if pos, ok := arg[uast.KeyPos]; ok {
+				ref[uast.KeyPos] = pos
+			}
+			argNames[string(s)] = ref
+		}
+	}
+	changed := false
+	group = group.CloneList()
+	for _, di := range docs {
+		doc := group[di].(nodes.Object).CloneObject()
+		tags, _ := doc["Tags"].(nodes.Array)
+		tags = tags.CloneList()
+		for ti, t := range tags {
+			tag, ok := t.(nodes.Object)
+			if !ok || tag["Name"] != nodes.String("param") {
+				continue
+			}
+			v, _ := tag["Var"].(nodes.String)
+			name, ok := argNames[string(v)]
+			if !ok {
+				continue
+			}
+			tag = tag.CloneObject()
+			tag["Argument"] = name.Clone()
+			tags[ti] = tag
+			changed = true
+		}
+		doc["Tags"] = tags
+		group[di] = doc
+	}
+	if !changed {
+		return obj, false, nil
+	}
+	obj = obj.CloneObject()
+	obj["Nodes"] = group
+	return obj, true, nil
+})
+
+// funcArgs returns the list of arguments of a uast.Function node.
+func funcArgs(n nodes.Node) nodes.Array {
+	fnc, ok := n.(nodes.Object)
+	if !ok {
+		return nil
+	}
+	typ, _ := fnc["Type"].(nodes.Object)
+	args, _ := typ["Arguments"].(nodes.Array)
+	return args
+}
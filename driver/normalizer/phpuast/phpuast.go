@@ -0,0 +1,385 @@
+// Package phpuast defines PHP-specific semantic UAST nodes.
+package phpuast
+
+import "github.com/bblfsh/sdk/v3/uast"
+
+func init() {
+	uast.RegisterPackage("phpuast",
+		AnchoredName{},
+		AnonymousClass{},
+		Array{},
+		ArrayItem{},
+		BuiltinType{},
+		ByRef{},
+		Capture{},
+		Catch{},
+		ClassLike{},
+		Closure{},
+		Declaration{},
+		Destructuring{},
+		DestructuringTarget{},
+		Doc{},
+		DocTag{},
+		Field{},
+		Global{},
+		IncludePath{},
+		ListPattern{},
+		Modifiers{},
+		New{},
+		Null{},
+		NullableType{},
+		ResolvedName{},
+		Static{},
+		StaticVar{},
+		StringTemplate{},
+		TraitAlias{},
+		TraitPrecedence{},
+		Try{},
+		UnionType{},
+		Use{},
+	)
+}
+
+// AnchoredName is a qualified name with an explicit anchor, like "\A\B" or "namespace\A\B".
+//
+// Anchor is "global" for fully qualified names and "namespace" for names relative to the current namespace.
+type AnchoredName struct {
+	uast.GenNode
+	Anchor string                    `json:"Anchor"` // "global" or "namespace"
+	Name   *uast.QualifiedIdentifier `json:"Name"`
+}
+
+// AnonymousClass is a declaration of a class without a name, like "class extends A implements B { ... }".
+//
+// Anonymous classes are always declared in a "new" expression, thus they are stored in the Type field
+// of the New node that instantiates the class.
+type AnonymousClass struct {
+	uast.GenNode
+	Modifiers  *Modifiers `json:"Modifiers"`
+	Extends    []uast.Any `json:"Extends"`
+	Implements []uast.Any `json:"Implements"`
+	Members    []uast.Any `json:"Members"`
+	Comments   []uast.Any `json:"Comments,omitempty"`
+}
+
+// Array is an array literal, like "[1, 2]" or "array('a' => $b)".
+//
+// Kind is "list" if none of the items have a key, and "map" otherwise. It is derived from the items.
+// Short is set for arrays declared with the short syntax. Arrays used as destructuring targets may contain
+// nil items for skipped elements.
+type Array struct {
+	uast.GenNode
+	Kind     string     `json:"Kind"` // "list" or "map"
+	Short    bool       `json:"Short"`
+	Items    []uast.Any `json:"Items"`
+	Comments []uast.Any `json:"Comments,omitempty"`
+}
+
+// ArrayItem is an element of an array literal with an optional key.
+//
+// ByRef is set for items that take a reference, like "&$a", and Unpack is set for spread items, like "...$a".
+type ArrayItem struct {
+	uast.GenNode
+	Key      uast.Any   `json:"Key"`
+	Value    uast.Any   `json:"Value"`
+	ByRef    bool       `json:"ByRef"`
+	Unpack   bool       `json:"Unpack,omitempty"`
+	Comments []uast.Any `json:"Comments,omitempty"`
+}
+
+// BuiltinType is a built-in type of an argument, a return value or a property, like "int", "array" or "callable".
+//
+// Class types, including self, parent and static, are stored as names.
+type BuiltinType struct {
+	uast.GenNode
+	Name string `json:"Name"`
+}
+
+// ByRef is a type of an argument or a return value that is passed by reference.
+//
+// It wraps the declared type, if any. For example, "function &f(array &$a, &$b)" has a return type
+// ByRef{Type: nil} and argument types ByRef{Type: BuiltinType{Name: "array"}} and ByRef{Type: nil}.
+type ByRef struct {
+	uast.GenNode
+	Type uast.Any `json:"Type"`
+}
+
+// Capture is a variable captured by a closure, like "$a" or "&$b" in "function () use ($a, &$b) { ... }".
+type Capture struct {
+	uast.GenNode
+	Name     *uast.Identifier `json:"Name"`
+	ByRef    bool             `json:"ByRef"`
+	Comments []uast.Any       `json:"Comments,omitempty"`
+}
+
+// Catch is a catch clause of a try statement, like "catch (A | B $e) { ... }".
+//
+// Types lists exception classes handled by the clause, and Var is the variable that is bound to the exception.
+// Var is nil if the exception is not bound to a variable.
+type Catch struct {
+	uast.GenNode
+	Types    []uast.Any       `json:"Types"`
+	Var      *uast.Identifier `json:"Var"`
+	Body     *uast.Block      `json:"Body"`
+	Comments []uast.Any       `json:"Comments,omitempty"`
+}
+
+// ClassLike is a body of a named class, interface or trait declaration. The declaration is an Alias that names
+// the ClassLike node.
+//
+// Kind is "class", "interface" or "trait". Classes may extend one class and implement any number of interfaces,
+// while interfaces may only extend other interfaces. Traits never extend anything.
+type ClassLike struct {
+	uast.GenNode
+	Kind       string     `json:"Kind"`
+	Extends    []uast.Any `json:"Extends"`
+	Implements []uast.Any `json:"Implements"`
+	Members    []uast.Any `json:"Members"`
+}
+
+// Closure describes an anonymous function, like "static function () use ($a) { ... }".
+//
+// Closures are stored in a FunctionGroup next to the uast.Function node they describe and have the same
+// position as the whole closure. Static is set for closures that are not bound to an object.
+type Closure struct {
+	uast.GenNode
+	Static   bool      `json:"Static"`
+	Captures []Capture `json:"Captures"`
+}
+
+// Declaration is a function, class, interface, trait or constant declared in a namespace, with the fully qualified
+// name of the declared symbol, like "App\Util\foo".
+//
+// Node is a FunctionGroup of a function, an Alias of a class, interface or trait, or a native constant.
+type Declaration struct {
+	uast.GenNode
+	FullName string   `json:"FullName"`
+	Node     uast.Any `json:"Node"`
+}
+
+// Destructuring is an assignment to multiple targets, like "list($a, 'k' => $b) = $x" or "[$a, [$b]] = $x".
+type Destructuring struct {
+	uast.GenNode
+	Pattern  *ListPattern `json:"Pattern"`
+	Value    uast.Any     `json:"Value"`
+	Comments []uast.Any   `json:"Comments,omitempty"`
+}
+
+// DestructuringTarget is a single target of a destructuring assignment with an optional key.
+//
+// Target is either an assignable expression, like a variable or a property, or a nested ListPattern.
+type DestructuringTarget struct {
+	uast.GenNode
+	Key      uast.Any   `json:"Key"`
+	Target   uast.Any   `json:"Target"`
+	ByRef    bool       `json:"ByRef"`
+	Comments []uast.Any `json:"Comments,omitempty"`
+}
+
+// Doc is a parsed PHPDoc comment, like "/** Summary. @param int $a */".
+//
+// The comment itself is kept as a uast.Comment, and the Doc node follows it in the same list and has the same
+// position. Summary is the first sentence or paragraph of the text, and Description is the rest of the text
+// before the tags.
+type Doc struct {
+	uast.GenNode
+	Summary     string   `json:"Summary"`
+	Description string   `json:"Description"`
+	Tags        []DocTag `json:"Tags"`
+}
+
+// DocTag is a single PHPDoc tag, like "@param int|null $a Description".
+//
+// Types lists alternatives of the type expression, if the tag has one, and Var is the name of the documented
+// variable without the "$" sign. Argument is set for @param tags that document an argument of the function;
+// it is a copy of the argument name with the position of the uast.Argument node.
+type DocTag struct {
+	uast.GenNode
+	Name     string           `json:"Name"`
+	Types    []string         `json:"Types"`
+	Var      string           `json:"Var"`
+	Text     string           `json:"Text"`
+	Argument *uast.Identifier `json:"Argument"`
+}
+
+// Field is a declaration of a class property or a class constant.
+//
+// Statements that declare multiple fields, like "public $a = 1, $b;", are split into separate Field nodes.
+// Modifiers are kept as written and have the position of the whole statement, while Visibility and Static
+// are derived from them; members without a visibility modifier are public.
+//
+// Comments are attached to the whole statement, while NameComments precede the name of the field,
+// like in "public /* c */ $a;".
+type Field struct {
+	uast.GenNode
+	Kind         string           `json:"Kind"` // "property" or "const"
+	Name         *uast.Identifier `json:"Name"`
+	Default      uast.Any         `json:"Default"`
+	Visibility   string           `json:"Visibility"`
+	Static       bool             `json:"Static"`
+	Modifiers    *Modifiers       `json:"Modifiers"`
+	NameComments []uast.Any       `json:"NameComments,omitempty"`
+	Comments     []uast.Any       `json:"Comments,omitempty"`
+}
+
+// Global is a declaration that binds local variables to global variables with the same names,
+// like "global $a, $$b;".
+//
+// Vars are variable expressions, thus names of the variables may be dynamic.
+type Global struct {
+	uast.GenNode
+	Vars     []uast.Any `json:"Vars"`
+	Comments []uast.Any `json:"Comments,omitempty"`
+}
+
+// IncludePath is a path of an include or require statement that is not a string literal,
+// like `__DIR__ . '/lib/db.php'` or `$dir . '/view.php'`.
+//
+// Paths built from string literals, __DIR__, __FILE__ and dirname(__FILE__) are evaluated statically. Base is
+// "__DIR__" or "__FILE__" if the path starts with the magic constant, and Value is the rest of the path.
+// Other paths are marked as Dynamic and have empty Base and Value. Expr is the original expression.
+type IncludePath struct {
+	uast.GenNode
+	Base    string   `json:"Base"`
+	Value   string   `json:"Value"`
+	Dynamic bool     `json:"Dynamic"`
+	Expr    uast.Any `json:"Expr"`
+}
+
+// ListPattern is a list of destructuring targets.
+//
+// Short is set for lists declared with the short array syntax. Skipped elements, like in "[, $a]", are nil.
+type ListPattern struct {
+	uast.GenNode
+	Short    bool       `json:"Short"`
+	Targets  []uast.Any `json:"Targets"`
+	Comments []uast.Any `json:"Comments,omitempty"`
+}
+
+// Modifiers lists modifier keywords of a declaration in a canonical order, like ["abstract", "public", "static"].
+//
+// The list is empty if the declaration has no modifiers.
+type Modifiers struct {
+	uast.GenNode
+	Flags []string `json:"Flags"`
+}
+
+// New is an instantiation of an anonymous class with the constructor arguments, like "new class($a) { ... }".
+//
+// Instantiations of named classes are kept as native nodes.
+type New struct {
+	uast.GenNode
+	Type      *AnonymousClass `json:"Type"`
+	Arguments []uast.Any      `json:"Arguments"`
+	Comments  []uast.Any      `json:"Comments,omitempty"`
+}
+
+// Null is a null literal. The constant is case-insensitive, thus the original spelling, like "NULL" or "\null",
+// is kept in the token.
+type Null struct {
+	uast.GenNode
+}
+
+// NullableType is a type that also accepts null, like "?int" or "?A".
+type NullableType struct {
+	uast.GenNode
+	Type     uast.Any   `json:"Type"`
+	Comments []uast.Any `json:"Comments,omitempty"`
+}
+
+// ResolvedName is a reference to a class, function or constant with the fully qualified name of the symbol,
+// like "App\Models\User" for "User" imported with "use App\Models\User;". Name is the name as written in the code.
+//
+// FallbackName is only set for unqualified functions and constants referenced in a namespace. PHP falls back
+// to the global symbol at runtime if the symbol is not defined in the namespace.
+type ResolvedName struct {
+	uast.GenNode
+	FullName     string   `json:"FullName"`
+	FallbackName string   `json:"FallbackName"`
+	Name         uast.Any `json:"Name"`
+}
+
+// Static is a declaration of static variables of a function, like "static $n = 0, $m;".
+//
+// Static variables keep their values between calls of the function.
+type Static struct {
+	uast.GenNode
+	Vars     []StaticVar `json:"Vars"`
+	Comments []uast.Any  `json:"Comments,omitempty"`
+}
+
+// StaticVar is a single static variable with an optional initializer. The initializer is only evaluated once.
+type StaticVar struct {
+	uast.GenNode
+	Name     *uast.Identifier `json:"Name"`
+	Default  uast.Any         `json:"Default"`
+	Comments []uast.Any       `json:"Comments,omitempty"`
+}
+
+// StringTemplate is an interpolated string, like "a $b {$c->d}".
+//
+// Parts lists literal parts and embedded expressions in order. Format is the same as for uast.String.
+type StringTemplate struct {
+	uast.GenNode
+	Format   string     `json:"Format"`
+	Parts    []uast.Any `json:"Parts"`
+	Comments []uast.Any `json:"Comments,omitempty"`
+}
+
+// TraitAlias is a trait adaptation rule that introduces an alias for a trait method or changes its visibility,
+// like "A::m as protected n;".
+//
+// Trait is nil if the trait is not specified, Name is nil if only the visibility is changed and Visibility
+// is empty if it is not changed.
+type TraitAlias struct {
+	uast.GenNode
+	Trait      uast.Any         `json:"Trait"`
+	Method     *uast.Identifier `json:"Method"`
+	Name       *uast.Identifier `json:"Name"`
+	Visibility string           `json:"Visibility"`
+	Comments   []uast.Any       `json:"Comments,omitempty"`
+}
+
+// TraitPrecedence is a trait adaptation rule that resolves a conflict between trait methods,
+// like "A::m insteadof B, C;". The method of the Trait is used instead of methods of Excluded traits.
+type TraitPrecedence struct {
+	uast.GenNode
+	Trait    uast.Any         `json:"Trait"`
+	Method   *uast.Identifier `json:"Method"`
+	Excluded []uast.Any       `json:"Excluded"`
+	Comments []uast.Any       `json:"Comments,omitempty"`
+}
+
+// Try is a try statement with catch clauses and an optional finally block.
+//
+// The Finally block has the position of the whole finally clause. FinallyComments lists comments that precede
+// the finally keyword.
+type Try struct {
+	uast.GenNode
+	Body            *uast.Block `json:"Body"`
+	Catches         []Catch     `json:"Catches"`
+	Finally         *uast.Block `json:"Finally"`
+	FinallyComments []uast.Any  `json:"FinallyComments,omitempty"`
+	Comments        []uast.Any  `json:"Comments,omitempty"`
+}
+
+// UnionType is a type that accepts values of any of the listed types, like "int|string".
+//
+// Union types were added in PHP 8.0 and are not produced by the current version of the parser.
+type UnionType struct {
+	uast.GenNode
+	Types    []uast.Any `json:"Types"`
+	Comments []uast.Any `json:"Comments,omitempty"`
+}
+
+// Use is a use statement that imports classes, functions or constants, like "use function A\b;".
+//
+// Kinds lists the kind of each imported name in the order of Import.Names: "class", "function" or "const".
+// Group is set for statements declared with the group use syntax, like "use A\{B, function c};".
+type Use struct {
+	uast.GenNode
+	Group    bool                `json:"Group"`
+	Kinds    []string            `json:"Kinds"`
+	Import   *uast.RuntimeImport `json:"Import"`
+	Comments []uast.Any          `json:"Comments,omitempty"`
+}
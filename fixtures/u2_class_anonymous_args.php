@@ -0,0 +1,4 @@
+<?php
+$logger = new class($path, 3) extends Base implements Logger, Countable {
+    public $path;
+};
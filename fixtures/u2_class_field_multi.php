@@ -0,0 +1,7 @@
+<?php
+class A {
+    /** @var int */
+    public $a = 1, $b;
+    const X = 1, Y = 2;
+    var $c;
+}
@@ -0,0 +1,7 @@
+<?php
+abstract class testcls1 {
+    public function &testfnc1(array $a, &$b = null) : string {
+        return $b;
+    }
+    abstract protected static function testfnc2(testcls1 $c);
+}
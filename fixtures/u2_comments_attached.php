@@ -0,0 +1,6 @@
+<?php
+/** Doc for foo */
+function foo() {}
+$a = new /* cls */ Foo();
+// call
+bar();
@@ -0,0 +1,9 @@
+<?php
+
+$a = TRUE;
+$b = False;
+$c = \true;
+$d = \NULL;
+$e = Null;
+$f = /* c */ true;
+$g = /* c */ null;
@@ -0,0 +1,12 @@
+<?php
+$a = <<<'NOW'
+raw $text\n
+NOW;
+$b = <<<EOT
+    Hello $name,
+    total: {$order->total} EUR
+EOT;
+$c = <<<EOT
+  indented body
+    and more
+EOT;
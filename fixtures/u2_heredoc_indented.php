@@ -0,0 +1,11 @@
+<?php
+$a = <<<EOT
+    Hello $name
+    EOT;
+$b = <<<'NOW'
+    raw $text
+    NOW;
+$c = <<<EOT
+      indented
+    body
+    EOT;
@@ -0,0 +1,6 @@
+<?php
+/** Imports the model. */
+use App\Models\User;
+/* helpers */
+use App\Util\{Str, function format};
+/* aliased */ use function App\Util\quote as q;
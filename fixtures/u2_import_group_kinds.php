@@ -0,0 +1,3 @@
+<?php
+use A\B\{C, function d, const E};
+use function F\{g, h as hh};
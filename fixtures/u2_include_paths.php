@@ -0,0 +1,5 @@
+<?php
+require __DIR__ . '/lib/' . 'db.php';
+include_once dirname(__FILE__) . '/config.php';
+require_once 'vendor/' . 'autoload.php';
+include $dir . '/view.php';
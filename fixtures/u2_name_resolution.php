@@ -0,0 +1,15 @@
+<?php
+namespace App;
+
+use Lib\Http\Client;
+use Lib\Models as M;
+use function Lib\Util\format;
+use const Lib\Util\LIMIT;
+
+new Client();
+new M\User();
+new \DateTime();
+new namespace\Local();
+format(LIMIT);
+strlen(PHP_EOL);
+Helper::run();
@@ -0,0 +1,8 @@
+<?php
+namespace App\Util {
+    function foo() {}
+    const BAR = 1;
+}
+namespace {
+    function main() {}
+}
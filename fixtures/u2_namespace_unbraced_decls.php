@@ -0,0 +1,9 @@
+<?php
+namespace App\Util;
+function foo() {}
+class Baz {
+    const QUX = 2;
+    function method() {}
+}
+interface Iface {}
+trait Tr {}
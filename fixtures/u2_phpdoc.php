@@ -0,0 +1,15 @@
+<?php
+/**
+ * Adds numbers.
+ *
+ * Longer description
+ * on two lines.
+ *
+ * @param int|float $a first operand
+ * @param array<int, string> ...$rest the rest
+ *   of operands
+ * @return int|float
+ * @throws \InvalidArgumentException when invalid
+ * @deprecated 2.0 use sum() instead
+ */
+function add($a, ...$rest) {}